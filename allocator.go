@@ -1,6 +1,9 @@
 package go_manual_memory
 
-import "unsafe"
+import (
+	"errors"
+	"unsafe"
+)
 
 type Address interface {
 	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
@@ -14,10 +17,68 @@ type Allocator interface {
 	RawFree(ptr unsafe.Pointer, len uintptr)
 }
 
+// Returned (possibly wrapped) by fallible allocation functions when an allocator
+// cannot provide the requested memory
+var ErrOutOfMemory = errors.New("go_manual_memory: out of memory")
+
+// An `Allocator` that can report failure to allocate as an error instead of panicking
+//
+// `RawAlloc()` on a `FallibleAllocator` is expected to panic on failure
+type FallibleAllocator interface {
+	Allocator
+	TryRawAlloc(len, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error)
+}
+
+// An `Allocator` that can tell whether a pointer was allocated from it
+//
+// `Owns()` MUST be safe to call with any pointer, including ones from other allocators.
+// Allocations that an allocator forwards to its parent are NOT reported as owned, since
+// the parent may be shared with other allocators. Allocators that only wrap another
+// allocator, like the `StatsAllocator`, report whatever the wrapped allocator does
+type Owner interface {
+	Allocator
+	Owns(ptr unsafe.Pointer) bool
+}
+
+// Return whether `ptr` was allocated from `alloc`, or false if `alloc` is not an `Owner`
+func Owns(alloc Allocator, ptr unsafe.Pointer) bool {
+	if owner, ok := alloc.(Owner); ok {
+		return owner.Owns(ptr)
+	}
+	return false
+}
+
+// Allocate raw memory from any `Allocator`, returning an error instead of panicking
+// if it cannot be provided
+//
+// If the allocator is not a `FallibleAllocator`, a nil pointer returned for a
+// non-zero length is reported as `ErrOutOfMemory`
+func TryRawAlloc(alloc Allocator, len, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if fallible, ok := alloc.(FallibleAllocator); ok {
+		return fallible.TryRawAlloc(len, align)
+	}
+	ptr, alloc_len = alloc.RawAlloc(len, align)
+	if ptr == nil && len > 0 {
+		return nil, 0, ErrOutOfMemory
+	}
+	return
+}
+
 func Alloc[T any](alloc Allocator, len int) (mem []T) {
+	mem, err := TryAlloc[T](alloc, len)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+func TryAlloc[T any](alloc Allocator, len int) (mem []T, err error) {
 	size := unsafe.Sizeof(*new(T))
 	align := unsafe.Alignof(*new(T))
-	ptr, allocLen := alloc.RawAlloc(size*uintptr(len), align)
+	ptr, allocLen, err := TryRawAlloc(alloc, size*uintptr(len), align)
+	if err != nil {
+		return nil, err
+	}
 	cap := allocLen / size
 	mem = unsafe.Slice((*T)(ptr), cap)[:len]
 	return
@@ -29,16 +90,34 @@ func ResizeInPlace[T any](alloc Allocator, mem []T, newLen int) (newMem []T, suc
 	newByteLen := size * uintptr(newLen)
 	ptr := unsafe.Pointer(unsafe.SliceData(mem))
 	newPtr, success := alloc.RawResizeInPlace(ptr, byteLen, newByteLen)
-	newMem = unsafe.Slice((*T)(newPtr), newLen)
-	return
+	if !success {
+		return mem, false
+	}
+	return unsafe.Slice((*T)(newPtr), newLen), true
 }
 
 func ResizeCanMove[T any](alloc Allocator, mem []T, newLen int) (newMem []T) {
+	newMem, err := TryResizeCanMove(alloc, mem, newLen)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// Like `ResizeCanMove()`, but returns an error instead of panicking if new memory
+// cannot be allocated, in which case the original memory is left untouched
+func TryResizeCanMove[T any](alloc Allocator, mem []T, newLen int) (newMem []T, err error) {
+	if cap(mem) == 0 {
+		return TryAlloc[T](alloc, newLen)
+	}
 	newMem, success := ResizeInPlace(alloc, mem, newLen)
 	if success {
 		return
 	}
-	newMem = Alloc[T](alloc, newLen)
+	newMem, err = TryAlloc[T](alloc, newLen)
+	if err != nil {
+		return mem, err
+	}
 	copy(newMem, mem)
 	Free(alloc, mem)
 	return
@@ -56,10 +135,20 @@ func Free[T any](alloc Allocator, mem []T) {
 // For many-item (vector) allocations, use the dedicated create function
 // for the vector type instead
 func Create[T any](alloc Allocator) *T {
+	ptr, err := TryCreate[T](alloc)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return ptr
+}
+
+// Like `Create()`, but returns an error instead of panicking if the
+// memory cannot be allocated
+func TryCreate[T any](alloc Allocator) (*T, error) {
 	size := unsafe.Sizeof(*new(T))
 	align := unsafe.Alignof(*new(T))
-	ptr, _ := alloc.RawAlloc(size, align)
-	return (*T)(ptr)
+	ptr, _, err := TryRawAlloc(alloc, size, align)
+	return (*T)(ptr), err
 }
 
 // Destroy (free) a single-item (scalar) pointer to a value of type `T`
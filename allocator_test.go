@@ -0,0 +1,273 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+// A block handed out by an allocator under test, filled with a single byte value
+// so any other block that overlaps it can be detected
+type testBlock struct {
+	ptr  unsafe.Pointer
+	len  uintptr
+	fill byte
+}
+
+func (b testBlock) bytes() []byte {
+	return unsafe.Slice((*byte)(b.ptr), b.len)
+}
+
+func fillTestBlock(b testBlock) {
+	for i := range b.bytes() {
+		b.bytes()[i] = b.fill
+	}
+}
+
+func checkTestBlock(b testBlock) error {
+	for i, val := range b.bytes() {
+		if val != b.fill {
+			return fmt.Errorf("\ntest case failed: block overwritten\nBLOCK: 0x%x (len %d)\nOFFSET: %d\nEXP BYTE: %d\nGOT BYTE: %d\n", uintptr(b.ptr), b.len, i, b.fill, val)
+		}
+	}
+	return nil
+}
+
+// Run a random sequence of allocations, in-place resizes, and frees against `alloc`,
+// checking that every block is aligned and that no block ever overwrites another
+//
+// Returns the blocks still live at the end, which the caller may free or inspect
+func stressAllocator(t *testing.T, alloc Allocator, seed int64, ops int, maxLen, maxAlign uintptr) []testBlock {
+	t.Helper()
+	live, err := runAllocatorStress(alloc, seed, ops, maxLen, maxAlign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return live
+}
+
+// Run `stressAllocator()` on `workers` goroutines at the same time, freeing
+// every block at the end
+//
+// Run with `go test -race` so the race detector can prove `alloc` is safe for concurrent use
+func stressAllocatorConcurrent(t *testing.T, alloc Allocator, workers int, maxLen, maxAlign uintptr) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			live, err := runAllocatorStress(alloc, int64(w), 2000, maxLen, maxAlign)
+			freeTestBlocks(alloc, live)
+			errs[w] = err
+		}()
+	}
+	wg.Wait()
+	for w, err := range errs {
+		if err != nil {
+			t.Errorf("\nWORKER: %d%v", w, err)
+		}
+	}
+}
+
+func runAllocatorStress(alloc Allocator, seed int64, ops int, maxLen, maxAlign uintptr) ([]testBlock, error) {
+	r := rand.New(rand.NewSource(seed))
+	live := make([]testBlock, 0, ops)
+	// Start each seed at a different fill value so blocks from concurrent workers differ
+	fill := byte(seed * 61)
+	for range ops {
+		switch op := r.Intn(8); {
+		case op < 4 || len(live) == 0:
+			size := uintptr(r.Int63n(int64(maxLen))) + 1
+			align := uintptr(1) << r.Intn(bits.Len(uint(maxAlign)))
+			ptr, alloc_len := alloc.RawAlloc(size, align)
+			if ptr == nil {
+				return live, fmt.Errorf("\ntest case failed: nil pointer\nLEN: %d\nALIGN: %d\n", size, align)
+			}
+			if uintptr(ptr)&(align-1) != 0 {
+				return live, fmt.Errorf("\ntest case failed: misaligned block\nBLOCK: 0x%x\nALIGN: %d\n", uintptr(ptr), align)
+			}
+			if alloc_len < size {
+				return live, fmt.Errorf("\ntest case failed: alloc_len too small\nEXP MIN: %d\nGOT: %d\n", size, alloc_len)
+			}
+			fill += 1
+			block := testBlock{ptr: ptr, len: size, fill: fill}
+			fillTestBlock(block)
+			live = append(live, block)
+		case op < 6:
+			idx := r.Intn(len(live))
+			block := live[idx]
+			if err := checkTestBlock(block); err != nil {
+				return live, err
+			}
+			alloc.RawFree(block.ptr, block.len)
+			live[idx] = live[len(live)-1]
+			live = live[:len(live)-1]
+		default:
+			idx := r.Intn(len(live))
+			block := live[idx]
+			if err := checkTestBlock(block); err != nil {
+				return live, err
+			}
+			newLen := uintptr(r.Int63n(int64(maxLen))) + 1
+			newPtr, success := alloc.RawResizeInPlace(block.ptr, block.len, newLen)
+			if !success {
+				continue
+			}
+			if newPtr != block.ptr {
+				return live, fmt.Errorf("\ntest case failed: in-place resize moved block\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(block.ptr), uintptr(newPtr))
+			}
+			block.len = newLen
+			fillTestBlock(block)
+			live[idx] = block
+		}
+	}
+	for _, block := range live {
+		if err := checkTestBlock(block); err != nil {
+			return live, err
+		}
+	}
+	return live, nil
+}
+
+func freeTestBlocks(alloc Allocator, blocks []testBlock) {
+	for _, block := range blocks {
+		alloc.RawFree(block.ptr, block.len)
+	}
+}
+
+// Fail the test if `action` does not panic
+func expectPanic(t *testing.T, what string, action func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("\ntest case failed: no panic for %s\n", what)
+		}
+	}()
+	action()
+}
+
+// An allocator that forwards to its parent, but fails every request longer than `maxLen`
+type failingAllocator struct {
+	parentAlloc Allocator
+	maxLen      uintptr
+}
+
+func (f failingAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := f.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+func (f failingAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len > f.maxLen {
+		return nil, 0, fmt.Errorf("%w: failingAllocator.TryRawAlloc(): len %d is over %d", ErrOutOfMemory, len, f.maxLen)
+	}
+	return TryRawAlloc(f.parentAlloc, len, align)
+}
+
+func (f failingAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	f.parentAlloc.RawFree(ptr, len)
+}
+
+func (f failingAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if new_len > f.maxLen {
+		return ptr, false
+	}
+	return f.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// An allocator that is not a `FallibleAllocator`, and returns nil for every request
+type nilAllocator struct{}
+
+func (nilAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	return nil, 0
+}
+
+func (nilAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {}
+
+func (nilAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	return ptr, false
+}
+
+func TestTryRawAlloc(t *testing.T) {
+	if _, _, err := TryRawAlloc(nilAllocator{}, 8, 8); err != ErrOutOfMemory {
+		t.Errorf("\ntest case failed: nil from a non-fallible allocator\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if ptr, _, err := TryRawAlloc(nilAllocator{}, 0, 8); ptr != nil || err != nil {
+		t.Errorf("\ntest case failed: nil for a zero length request\nGOT ERR: %v\n", err)
+	}
+	if _, _, err := TryRawAlloc(failingAllocator{NewGoAllocator(), 8}, 16, 8); !errors.Is(err, ErrOutOfMemory) || err == ErrOutOfMemory {
+		t.Errorf("\ntest case failed: wrapped error from a fallible allocator\nGOT ERR: %v\n", err)
+	}
+	if _, _, err := TryRawAlloc(failingAllocator{NewGoAllocator(), 8}, 8, 8); err != nil {
+		t.Errorf("\ntest case failed: fallible allocator success\nGOT ERR: %v\n", err)
+	}
+}
+
+func TestTryAlloc(t *testing.T) {
+	parent := NewGoAllocator()
+	// An arena keeps every allocation inside one large block from its parent
+	arena := NewArenaAllocator(parent, PAGE_SIZE)
+	alloc := failingAllocator{arena, 64}
+	if mem, err := TryAlloc[uint64](alloc, 9); !errors.Is(err, ErrOutOfMemory) || mem != nil {
+		t.Errorf("\ntest case failed: TryAlloc() over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if ptr, err := TryCreate[[9]uint64](alloc); !errors.Is(err, ErrOutOfMemory) || ptr != nil {
+		t.Errorf("\ntest case failed: TryCreate() over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	expectPanic(t, "Alloc() over the limit", func() { Alloc[uint64](alloc, 9) })
+	expectPanic(t, "Create() over the limit", func() { Create[[9]uint64](alloc) })
+	mem, err := TryAlloc[uint64](alloc, 4)
+	if err != nil || len(mem) != 4 {
+		t.Fatalf("\ntest case failed: TryAlloc() under the limit\nLEN: %d\nGOT ERR: %v\n", len(mem), err)
+	}
+	for i := range mem {
+		mem[i] = uint64(i)
+	}
+	newMem, err := TryResizeCanMove(alloc, mem, 9)
+	if !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: TryResizeCanMove() over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if unsafe.SliceData(newMem) != unsafe.SliceData(mem) || len(newMem) != 4 || newMem[3] != 3 {
+		t.Errorf("\ntest case failed: original memory not returned untouched after failure\nGOT: %v\n", newMem)
+	}
+	expectPanic(t, "ResizeCanMove() over the limit", func() { ResizeCanMove(alloc, mem, 9) })
+	newMem, err = TryResizeCanMove(alloc, mem, 8)
+	if err != nil || len(newMem) != 8 || newMem[3] != 3 {
+		t.Errorf("\ntest case failed: TryResizeCanMove() under the limit\nGOT: %v\nGOT ERR: %v\n", newMem, err)
+	}
+	Free(alloc, newMem)
+	arena.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestResizeInPlace(t *testing.T) {
+	parent := NewGoAllocator()
+	mem := Alloc[uint64](parent, 4)
+	for i := range mem {
+		mem[i] = uint64(i)
+	}
+	// Far past the end of the Golang allocation, so the failed resize must not build a slice that long
+	newMem, ok := ResizeInPlace(parent, mem, 1<<20)
+	if ok || unsafe.SliceData(newMem) != unsafe.SliceData(mem) || len(newMem) != 4 || newMem[3] != 3 {
+		t.Errorf("\ntest case failed: original memory not returned untouched after failure\nOK: %v\nGOT LEN: %d\n", ok, len(newMem))
+	}
+	newMem, ok = ResizeInPlace(parent, mem, 2)
+	if !ok || unsafe.SliceData(newMem) != unsafe.SliceData(mem) || len(newMem) != 2 || cap(newMem) != 2 || newMem[1] != 1 {
+		t.Errorf("\ntest case failed: shrink in place\nOK: %v\nGOT: %v\n", ok, newMem)
+	}
+	Free(parent, mem)
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", parent.LiveCount())
+	}
+}
@@ -0,0 +1,187 @@
+package go_manual_memory
+
+import (
+	"slices"
+	"unsafe"
+)
+
+// The block size used by an `ArenaAllocator` when none is provided
+const DEFAULT_ARENA_BLOCK_SIZE = PAGE_SIZE * 8
+
+// This allocator requests blocks of memory from a parent allocator and hands out
+// memory by simply 'bumping' an offset forward in the current block
+//
+// Individual frees are a no-op, EXCEPT for the most recent allocation, which can be
+// freed or resized in place. All memory can be released at once with `Reset()`, or
+// back to a previous point with `Checkpoint()` and `Rollback()`
+//
+// Blocks are kept for reuse after a `Reset()` or `Rollback()`, and are only returned
+// to the parent allocator when `Destroy()` is called
+type ArenaAllocator struct {
+	parentAlloc Allocator
+	blockSize   uintptr
+	blocks      [][]byte
+	block       int
+	offset      uintptr
+	lastStart   uintptr
+	hasLast     bool
+}
+
+// A saved position in an `ArenaAllocator` that can be returned to with `Rollback()`
+type ArenaCheckpoint struct {
+	block  int
+	offset uintptr
+}
+
+// Create a new `ArenaAllocator` that requests blocks of at least `blockSize` bytes
+// from the parent allocator
+//
+// If `blockSize` is 0, `DEFAULT_ARENA_BLOCK_SIZE` is used
+func NewArenaAllocator(parent Allocator, blockSize uintptr) *ArenaAllocator {
+	if blockSize == 0 {
+		blockSize = DEFAULT_ARENA_BLOCK_SIZE
+	}
+	return &ArenaAllocator{
+		parentAlloc: parent,
+		blockSize:   alignForward(blockSize, PAGE_SIZE),
+		blocks:      make([][]byte, 0),
+	}
+}
+
+func (a *ArenaAllocator) blockAddr(block int) uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(a.blocks[block])))
+}
+
+// Return the offset `len` bytes could be placed at with the requested alignment
+// in block `block`, starting from `offset`
+func (a *ArenaAllocator) fitInBlock(block int, offset, len, align uintptr) (start uintptr, fits bool) {
+	addr := a.blockAddr(block)
+	start = alignForward(addr+offset, align) - addr
+	return start, start+len <= uintptr(cap(a.blocks[block]))
+}
+
+func (a *ArenaAllocator) blockCount() int {
+	return len(a.blocks)
+}
+
+// RawAlloc implements Allocator.
+func (a *ArenaAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := a.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (a *ArenaAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	offset := a.offset
+	for block := a.block; block < a.blockCount(); block += 1 {
+		if start, fits := a.fitInBlock(block, offset, len, align); fits {
+			ptr, alloc_len = a.bump(block, start, len)
+			return ptr, alloc_len, nil
+		}
+		offset = 0
+	}
+	// Insert the new block directly after the current one so that
+	// any existing checkpoints remain valid
+	block := min(a.block+1, a.blockCount())
+	blockLen := alignForward(max(a.blockSize, len+align), PAGE_SIZE)
+	blockPtr, blockCap, err := TryRawAlloc(a.parentAlloc, blockLen, align)
+	if err != nil {
+		return nil, 0, err
+	}
+	a.blocks = slices.Insert(a.blocks, block, unsafe.Slice((*byte)(blockPtr), blockCap))
+	start, _ := a.fitInBlock(block, 0, len, align)
+	ptr, alloc_len = a.bump(block, start, len)
+	return ptr, alloc_len, nil
+}
+
+func (a *ArenaAllocator) bump(block int, start, len uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	a.block = block
+	a.offset = start + len
+	a.lastStart = start
+	a.hasLast = true
+	return unsafe.Pointer(&a.blocks[block][start]), len
+}
+
+func (a *ArenaAllocator) isLast(ptr unsafe.Pointer) bool {
+	return a.hasLast && a.blockCount() > 0 && uintptr(ptr) == a.blockAddr(a.block)+a.lastStart
+}
+
+// RawFree implements Allocator.
+//
+// Only the most recent allocation is actually released, all others are a no-op
+func (a *ArenaAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || !a.isLast(ptr) {
+		return
+	}
+	a.offset = a.lastStart
+	a.hasLast = false
+}
+
+// RawResizeInPlace implements Allocator.
+//
+// Shrinking always succeeds, but only the most recent allocation can grow
+func (a *ArenaAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if !a.isLast(ptr) {
+		return ptr, new_len <= old_len
+	}
+	if a.lastStart+new_len > uintptr(cap(a.blocks[a.block])) {
+		return ptr, false
+	}
+	a.offset = a.lastStart + new_len
+	return ptr, true
+}
+
+// Owns implements Owner.
+func (a *ArenaAllocator) Owns(ptr unsafe.Pointer) bool {
+	for _, block := range a.blocks {
+		if blockContains(block, ptr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return a checkpoint representing the current position of the arena
+func (a *ArenaAllocator) Checkpoint() ArenaCheckpoint {
+	return ArenaCheckpoint{
+		block:  a.block,
+		offset: a.offset,
+	}
+}
+
+// Free all memory allocated since checkpoint `cp` was created
+//
+// All memory allocated after the checkpoint becomes invalid, along with
+// any checkpoints created after it
+func (a *ArenaAllocator) Rollback(cp ArenaCheckpoint) {
+	a.block = cp.block
+	a.offset = cp.offset
+	a.hasLast = false
+}
+
+// Free all memory allocated from this arena at once, keeping the blocks for reuse
+//
+// All memory allocated from this allocator becomes invalid
+func (a *ArenaAllocator) Reset() {
+	a.Rollback(ArenaCheckpoint{})
+}
+
+// Return all blocks to the parent allocator
+//
+// All memory allocated from this allocator becomes invalid
+func (a *ArenaAllocator) Destroy() {
+	for _, block := range a.blocks {
+		a.parentAlloc.RawFree(unsafe.Pointer(unsafe.SliceData(block)), uintptr(cap(block)))
+	}
+	a.blocks = a.blocks[:0]
+	a.Reset()
+}
+
+var _ FallibleAllocator = (*ArenaAllocator)(nil)
+var _ Owner = (*ArenaAllocator)(nil)
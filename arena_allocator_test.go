@@ -0,0 +1,100 @@
+package go_manual_memory
+
+import "testing"
+
+func TestArenaAllocator(t *testing.T) {
+	a := NewArenaAllocator(NewGoAllocator(), 0)
+	live := stressAllocator(t, a, 1, 5000, 512, 64)
+	for _, block := range live {
+		if !a.Owns(block.ptr) {
+			t.Fatalf("\ntest case failed: live block not owned\nBLOCK: 0x%x\n", uintptr(block.ptr))
+		}
+	}
+	a.Destroy()
+}
+
+func TestArenaAllocatorReset(t *testing.T) {
+	a := NewArenaAllocator(NewGoAllocator(), 0)
+	blockCount := 0
+	for round := range 3 {
+		slices := make([]Slice[uint64], 0, 1000)
+		for i := range 1000 {
+			s := CreateSlice[uint64](i%300+1, a)
+			for j := range s.GoSlice() {
+				s.GoSlice()[j] = uint64(i)
+			}
+			slices = append(slices, s)
+		}
+		CreateSlice[byte](DEFAULT_ARENA_BLOCK_SIZE*2, a)
+		for i, s := range slices {
+			for _, val := range s.GoSlice() {
+				if val != uint64(i) {
+					t.Fatalf("\ntest case failed: slice overwritten\nROUND: %d\nEXP: %d\nGOT: %d\n", round, i, val)
+				}
+			}
+		}
+		if round == 0 {
+			blockCount = a.blockCount()
+		} else if a.blockCount() != blockCount {
+			t.Errorf("\ntest case failed: blocks were not reused after Reset()\nEXP: %d\nGOT: %d\n", blockCount, a.blockCount())
+		}
+		a.Reset()
+	}
+	a.Destroy()
+	if a.blockCount() != 0 {
+		t.Errorf("\ntest case failed: blocks left after Destroy()\nGOT: %d\n", a.blockCount())
+	}
+}
+
+func TestArenaAllocatorLastAllocation(t *testing.T) {
+	a := NewArenaAllocator(NewGoAllocator(), 0)
+	first, _ := a.RawAlloc(64, 8)
+	second, _ := a.RawAlloc(64, 8)
+	if _, ok := a.RawResizeInPlace(first, 64, 128); ok {
+		t.Errorf("\ntest case failed: grew an allocation that is not the most recent\n")
+	}
+	if _, ok := a.RawResizeInPlace(first, 64, 32); !ok {
+		t.Errorf("\ntest case failed: could not shrink an allocation\n")
+	}
+	if _, ok := a.RawResizeInPlace(second, 64, 1024); !ok {
+		t.Errorf("\ntest case failed: could not grow the most recent allocation\n")
+	}
+	third, _ := a.RawAlloc(8, 8)
+	if uintptr(third) != uintptr(second)+1024 {
+		t.Errorf("\ntest case failed: allocation after growth\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(second)+1024, uintptr(third))
+	}
+	a.RawFree(third, 8)
+	fourth, _ := a.RawAlloc(8, 8)
+	if fourth != third {
+		t.Errorf("\ntest case failed: most recent allocation was not freed\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(third), uintptr(fourth))
+	}
+	a.RawFree(first, 32)
+	fifth, _ := a.RawAlloc(8, 8)
+	if fifth == first {
+		t.Errorf("\ntest case failed: an older allocation was freed\n")
+	}
+	a.Destroy()
+}
+
+func TestArenaAllocatorCheckpoint(t *testing.T) {
+	a := NewArenaAllocator(NewGoAllocator(), PAGE_SIZE)
+	a.RawAlloc(100, 8)
+	cp := a.Checkpoint()
+	after, _ := a.RawAlloc(100, 8)
+	for range 10 {
+		a.RawAlloc(PAGE_SIZE/2, 8)
+	}
+	blockCount := a.blockCount()
+	a.Rollback(cp)
+	again, _ := a.RawAlloc(100, 8)
+	if again != after {
+		t.Errorf("\ntest case failed: allocation after Rollback()\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(after), uintptr(again))
+	}
+	for range 10 {
+		a.RawAlloc(PAGE_SIZE/2, 8)
+	}
+	if a.blockCount() != blockCount {
+		t.Errorf("\ntest case failed: blocks were not reused after Rollback()\nEXP: %d\nGOT: %d\n", blockCount, a.blockCount())
+	}
+	a.Destroy()
+}
@@ -0,0 +1,120 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// A range of allocation lengths handled by one child allocator of a `Bucketizer`
+type Bucket struct {
+	// The largest length handled by this bucket. Each bucket handles every
+	// length greater than the `MaxLen` of the bucket before it
+	MaxLen uintptr
+	Alloc  Allocator
+}
+
+// This allocator spreads ranges of allocation lengths over a list of child allocators,
+// sending each request to the first bucket whose `MaxLen` can hold it. Requests larger
+// than the last bucket fail with `ErrOutOfMemory`, so a `Bucketizer` is usually the
+// `Small` side of a `Segregator`
+//
+// `Buckets` MUST be sorted by `MaxLen` in ascending order. Frees and resizes are routed by
+// length in the same way. The `alloc_len` returned for a request never exceeds the `MaxLen`
+// of its bucket, so freeing with either the requested length or `alloc_len` always reaches
+// the same bucket, and resizing in place across buckets always fails. Create one directly
+// with a struct literal or with `NewBucketizer()`
+//
+// The `Bucketizer` is only safe for concurrent use if all child allocators are
+type Bucketizer struct {
+	Buckets []Bucket
+}
+
+// Create a new `Bucketizer` with a bucket for every multiple of `step` bytes from `minLen`
+// up to `maxLen`, calling `newAlloc` with the `MaxLen` of each bucket to create its allocator
+func NewBucketizer(minLen, maxLen, step uintptr, newAlloc func(maxLen uintptr) Allocator) *Bucketizer {
+	if step == 0 {
+		panic("fatal: go_manual_memory: NewBucketizer(): step cannot be 0")
+	}
+	buckets := make([]Bucket, 0)
+	for bucketLen := minLen; bucketLen <= maxLen; bucketLen += step {
+		buckets = append(buckets, Bucket{
+			MaxLen: bucketLen,
+			Alloc:  newAlloc(bucketLen),
+		})
+	}
+	return &Bucketizer{
+		Buckets: buckets,
+	}
+}
+
+// Return the index of the first bucket that can hold `size` bytes
+func (b *Bucketizer) bucketFor(size uintptr) (idx int, found bool) {
+	lo, hi := 0, len(b.Buckets)
+	for lo < hi {
+		mid := lo + ((hi - lo) >> 1)
+		if b.Buckets[mid].MaxLen < size {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(b.Buckets)
+}
+
+// RawAlloc implements Allocator.
+func (b *Bucketizer) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := b.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (b *Bucketizer) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	idx, found := b.bucketFor(len)
+	if !found {
+		return nil, 0, fmt.Errorf("%w: Bucketizer.TryRawAlloc(): requested len %d is greater than the largest bucket", ErrOutOfMemory, len)
+	}
+	bucket := b.Buckets[idx]
+	ptr, alloc_len, err = TryRawAlloc(bucket.Alloc, len, align)
+	return ptr, min(alloc_len, bucket.MaxLen), err
+}
+
+// RawFree implements Allocator.
+func (b *Bucketizer) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	idx, found := b.bucketFor(len)
+	if !found {
+		panic(fmt.Sprintf("fatal: go_manual_memory: Bucketizer.RawFree(): len %d is greater than the largest bucket", len))
+	}
+	b.Buckets[idx].Alloc.RawFree(ptr, len)
+}
+
+// RawResizeInPlace implements Allocator.
+func (b *Bucketizer) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	oldIdx, oldFound := b.bucketFor(old_len)
+	newIdx, newFound := b.bucketFor(new_len)
+	if !oldFound || !newFound || oldIdx != newIdx {
+		return ptr, false
+	}
+	return b.Buckets[oldIdx].Alloc.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// Owns implements Owner.
+func (b *Bucketizer) Owns(ptr unsafe.Pointer) bool {
+	for _, bucket := range b.Buckets {
+		if Owns(bucket.Alloc, ptr) {
+			return true
+		}
+	}
+	return false
+}
+
+var _ FallibleAllocator = (*Bucketizer)(nil)
+var _ Owner = (*Bucketizer)(nil)
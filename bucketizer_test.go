@@ -0,0 +1,86 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestBucketizer(t *testing.T) {
+	parents := make([]*GoAllocator, 0)
+	b := NewBucketizer(16, 64, 16, func(maxLen uintptr) Allocator {
+		parent := NewGoAllocator()
+		parents = append(parents, parent)
+		return parent
+	})
+	if len(b.Buckets) != 4 || b.Buckets[0].MaxLen != 16 || b.Buckets[3].MaxLen != 64 {
+		t.Fatalf("\ntest case failed: buckets\nEXP: 4 from 16 to 64\nGOT: %+v\n", b.Buckets)
+	}
+	tests := []struct {
+		len   uintptr
+		idx   int
+		found bool
+	}{
+		{1, 0, true},
+		{16, 0, true},
+		{17, 1, true},
+		{48, 2, true},
+		{64, 3, true},
+		{65, 4, false},
+	}
+	for _, test := range tests {
+		if idx, found := b.bucketFor(test.len); idx != test.idx || found != test.found {
+			t.Errorf("\ntest case failed: bucket for length\nLEN: %d\nEXP: %d, %v\nGOT: %d, %v\n", test.len, test.idx, test.found, idx, found)
+		}
+	}
+	ptr, alloc_len := b.RawAlloc(20, 8)
+	if !parents[1].Owns(ptr) || alloc_len < 20 || alloc_len > 32 {
+		t.Errorf("\ntest case failed: allocation not from its bucket\nOWNED: %v\nLEN: %d\n", parents[1].Owns(ptr), alloc_len)
+	}
+	if !b.Owns(ptr) {
+		t.Errorf("\ntest case failed: allocation not owned\n")
+	}
+	var local [16]byte
+	if b.Owns(unsafe.Pointer(&local)) {
+		t.Errorf("\ntest case failed: foreign pointer is owned\n")
+	}
+	if _, ok := b.RawResizeInPlace(ptr, 20, 17); !ok {
+		t.Errorf("\ntest case failed: resize within a bucket\n")
+	}
+	if _, ok := b.RawResizeInPlace(ptr, 17, 33); ok {
+		t.Errorf("\ntest case failed: resize across buckets\n")
+	}
+	if _, ok := b.RawResizeInPlace(ptr, 17, 65); ok {
+		t.Errorf("\ntest case failed: resize past the largest bucket\n")
+	}
+	// Freeing with `alloc_len` must reach the same bucket as the requested length
+	b.RawFree(ptr, alloc_len)
+	if parents[1].LiveCount() != 0 {
+		t.Errorf("\ntest case failed: free not routed to its bucket\nGOT: %d\n", parents[1].LiveCount())
+	}
+	if ptr, alloc_len := b.RawAlloc(0, 8); ptr != nil || alloc_len != 0 {
+		t.Errorf("\ntest case failed: zero length allocation\nGOT: %p, %d\n", ptr, alloc_len)
+	}
+	b.RawFree(nil, 16)
+}
+
+func TestBucketizerErrors(t *testing.T) {
+	b := NewBucketizer(16, 64, 16, func(maxLen uintptr) Allocator {
+		return NewGoAllocator()
+	})
+	if _, _, err := b.TryRawAlloc(65, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: request larger than the largest bucket\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	expectPanic(t, "RawAlloc() larger than the largest bucket", func() { b.RawAlloc(65, 8) })
+	var local [16]byte
+	expectPanic(t, "RawFree() larger than the largest bucket", func() { b.RawFree(unsafe.Pointer(&local), 65) })
+	expectPanic(t, "NewBucketizer() with a step of 0", func() {
+		NewBucketizer(16, 64, 0, func(maxLen uintptr) Allocator { return NewGoAllocator() })
+	})
+	b = NewBucketizer(16, 32, 16, func(maxLen uintptr) Allocator {
+		return failingAllocator{NewGoAllocator(), 20}
+	})
+	if _, _, err := b.TryRawAlloc(24, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: bucket allocator fails\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+}
@@ -0,0 +1,303 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"math/bits"
+	"unsafe"
+)
+
+// The smallest block handed out by a `BuddyAllocator`
+const MIN_BUDDY_BLOCK_SIZE = 16
+
+// The size of each arena a `BuddyAllocator` requests from its parent if none is specified
+const DEFAULT_BUDDY_ARENA_SIZE = PAGE_SIZE * 128
+
+// Stored at the start of every free block, forming a doubly-linked list per order
+type buddyFreeBlock struct {
+	prev *buddyFreeBlock
+	next *buddyFreeBlock
+}
+
+// A single power-of-2 sized region split into buddy blocks
+type buddyArena struct {
+	mem []byte
+	// One bit per possible block, set when that block is free,
+	// laid out as a binary tree with the whole arena at index 1
+	freeBits []uint64
+}
+
+func (a *buddyArena) base() uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(a.mem)))
+}
+
+// This allocator requests power-of-2 sized arenas from the parent allocator and splits them
+// into power-of-2 sized blocks, where each block can only merge with its 'buddy', the other
+// half of the block it was split from
+//
+// Allocating and freeing both take O(log n) time in the number of block sizes, and
+// fragmentation is predictable: every request is rounded up to the next power of 2,
+// so at most half of any block is unused, and free neighbors are always merged
+//
+// Resizing in place succeeds when shrinking, or when growing and every buddy needed to
+// reach the new size is free
+//
+// Requests larger than an arena are forwarded to the parent allocator, and freed
+// based on the length passed to `RawFree()`. Arenas are only requested with `PAGE_SIZE`
+// alignment, so each block is aligned to the smaller of its size and `PAGE_SIZE`, and
+// requests may ask for up to `PAGE_SIZE`
+type BuddyAllocator struct {
+	parentAlloc Allocator
+	arenaSize   uintptr
+	maxOrder    int
+	arenas      []buddyArena
+	freeLists   []*buddyFreeBlock
+}
+
+// Create a new `BuddyAllocator` that requests arenas of `arenaSize` bytes from the parent
+// allocator, rounded up to a power of 2 and at least `PAGE_SIZE`.
+// If `arenaSize` is 0, `DEFAULT_BUDDY_ARENA_SIZE` is used
+func NewBuddyAllocator(parent Allocator, arenaSize uintptr) *BuddyAllocator {
+	if arenaSize == 0 {
+		arenaSize = DEFAULT_BUDDY_ARENA_SIZE
+	}
+	arenaSize = max(buddyBlockSize(arenaSize), PAGE_SIZE)
+	maxOrder := buddyOrder(arenaSize)
+	return &BuddyAllocator{
+		parentAlloc: parent,
+		arenaSize:   arenaSize,
+		maxOrder:    maxOrder,
+		arenas:      make([]buddyArena, 0),
+		freeLists:   make([]*buddyFreeBlock, maxOrder+1),
+	}
+}
+
+// Round `len` up to the size of the block that would hold it
+func buddyBlockSize(len uintptr) uintptr {
+	if len <= MIN_BUDDY_BLOCK_SIZE {
+		return MIN_BUDDY_BLOCK_SIZE
+	}
+	return 1 << bits.Len(uint(len-1))
+}
+
+// Return the order of the block that would hold `len` bytes, where order 0
+// is `MIN_BUDDY_BLOCK_SIZE`
+func buddyOrder(len uintptr) int {
+	return bits.Len(uint(buddyBlockSize(len)-1)) - bits.Len(uint(MIN_BUDDY_BLOCK_SIZE-1))
+}
+
+func buddyOrderSize(order int) uintptr {
+	return MIN_BUDDY_BLOCK_SIZE << order
+}
+
+// Return the index of the free bit for the block of the order at offset `off` into an arena
+func (b *BuddyAllocator) bitIndex(order int, off uintptr) uintptr {
+	return (1 << (b.maxOrder - order)) + (off >> bits.Len(uint(buddyOrderSize(order)-1)))
+}
+
+func (b *BuddyAllocator) isFree(arena *buddyArena, order int, off uintptr) bool {
+	idx := b.bitIndex(order, off)
+	return arena.freeBits[idx/64]&(1<<(idx%64)) != 0
+}
+
+func (b *BuddyAllocator) pushFree(arena *buddyArena, order int, off uintptr) {
+	idx := b.bitIndex(order, off)
+	arena.freeBits[idx/64] |= 1 << (idx % 64)
+	block := (*buddyFreeBlock)(unsafe.Pointer(&arena.mem[off]))
+	storePtr(&block.prev, nil)
+	storePtr(&block.next, b.freeLists[order])
+	if block.next != nil {
+		storePtr(&block.next.prev, block)
+	}
+	b.freeLists[order] = block
+}
+
+func (b *BuddyAllocator) removeFree(arena *buddyArena, order int, off uintptr) {
+	idx := b.bitIndex(order, off)
+	arena.freeBits[idx/64] &^= 1 << (idx % 64)
+	block := (*buddyFreeBlock)(unsafe.Pointer(&arena.mem[off]))
+	if block.prev == nil {
+		b.freeLists[order] = block.next
+	} else {
+		storePtr(&block.prev.next, block.next)
+	}
+	if block.next != nil {
+		storePtr(&block.next.prev, block.prev)
+	}
+}
+
+// Return the arena holding `ptr`
+func (b *BuddyAllocator) arenaOf(ptr unsafe.Pointer) (arena *buddyArena, found bool) {
+	addr := uintptr(ptr)
+	lo, hi := 0, len(b.arenas)
+	for lo < hi {
+		mid := lo + ((hi - lo) >> 1)
+		if b.arenas[mid].base() > addr {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if lo == 0 {
+		return nil, false
+	}
+	arena = &b.arenas[lo-1]
+	if addr >= arena.base()+b.arenaSize {
+		return nil, false
+	}
+	return arena, true
+}
+
+func (b *BuddyAllocator) addNewArena() error {
+	ptr, _, err := TryRawAlloc(b.parentAlloc, b.arenaSize, PAGE_SIZE)
+	if err != nil {
+		return err
+	}
+	arena := buddyArena{
+		mem:      unsafe.Slice((*byte)(ptr), b.arenaSize),
+		freeBits: make([]uint64, ((2<<b.maxOrder)+63)/64),
+	}
+	idx := len(b.arenas)
+	for idx > 0 && b.arenas[idx-1].base() > arena.base() {
+		idx -= 1
+	}
+	b.arenas = append(b.arenas, buddyArena{})
+	copy(b.arenas[idx+1:], b.arenas[idx:])
+	b.arenas[idx] = arena
+	b.pushFree(&b.arenas[idx], b.maxOrder, 0)
+	return nil
+}
+
+// RawAlloc implements Allocator.
+func (b *BuddyAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := b.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (b *BuddyAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	if len > b.arenaSize {
+		return TryRawAlloc(b.parentAlloc, len, align)
+	}
+	if align > PAGE_SIZE {
+		return nil, 0, fmt.Errorf("%w: BuddyAllocator.TryRawAlloc(): requested align %d is greater than PAGE_SIZE", ErrOutOfMemory, align)
+	}
+	// Every block is aligned to its size up to `PAGE_SIZE`, so an over-aligned request is
+	// split from a block of at least `align` bytes, keeping the lower half each time. The
+	// block returned is still sized for `len` alone, so `RawFree()` finds the same order
+	// from the length
+	order := buddyOrder(len)
+	found := buddyOrder(max(len, align))
+	for found <= b.maxOrder && b.freeLists[found] == nil {
+		found += 1
+	}
+	if found > b.maxOrder {
+		if err = b.addNewArena(); err != nil {
+			return nil, 0, err
+		}
+		found = b.maxOrder
+	}
+	block := unsafe.Pointer(b.freeLists[found])
+	arena, _ := b.arenaOf(block)
+	off := uintptr(block) - arena.base()
+	b.removeFree(arena, found, off)
+	for found > order {
+		found -= 1
+		b.pushFree(arena, found, off+buddyOrderSize(found))
+	}
+	return block, buddyOrderSize(order), nil
+}
+
+// RawFree implements Allocator.
+//
+// The block is merged with its buddy for as long as the buddy is also free
+func (b *BuddyAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	if len > b.arenaSize {
+		b.parentAlloc.RawFree(ptr, len)
+		return
+	}
+	arena, found := b.arenaOf(ptr)
+	if !found {
+		panic(fmt.Sprintf("fatal: go_manual_memory: BuddyAllocator.RawFree(): pointer 0x%x was not allocated by this allocator", uintptr(ptr)))
+	}
+	off := uintptr(ptr) - arena.base()
+	order := buddyOrder(len)
+	for order < b.maxOrder {
+		buddy := off ^ buddyOrderSize(order)
+		if !b.isFree(arena, order, buddy) {
+			break
+		}
+		b.removeFree(arena, order, buddy)
+		off = min(off, buddy)
+		order += 1
+	}
+	b.pushFree(arena, order, off)
+}
+
+// RawResizeInPlace implements Allocator.
+//
+// Growing succeeds only if the block is the lower half of every merge needed to reach
+// the new size, and every buddy it would merge with is free
+func (b *BuddyAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if old_len > b.arenaSize {
+		if new_len <= b.arenaSize {
+			return ptr, false
+		}
+		return b.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	if new_len > b.arenaSize {
+		return ptr, false
+	}
+	arena, found := b.arenaOf(ptr)
+	if !found {
+		return ptr, false
+	}
+	off := uintptr(ptr) - arena.base()
+	oldOrder := buddyOrder(old_len)
+	newOrder := buddyOrder(new_len)
+	if newOrder < oldOrder {
+		for order := oldOrder - 1; order >= newOrder; order -= 1 {
+			b.pushFree(arena, order, off+buddyOrderSize(order))
+		}
+		return ptr, true
+	}
+	for order := oldOrder; order < newOrder; order += 1 {
+		size := buddyOrderSize(order)
+		if off&size != 0 || !b.isFree(arena, order, off+size) {
+			return ptr, false
+		}
+	}
+	for order := oldOrder; order < newOrder; order += 1 {
+		b.removeFree(arena, order, off+buddyOrderSize(order))
+	}
+	return ptr, true
+}
+
+// Owns implements Owner.
+func (b *BuddyAllocator) Owns(ptr unsafe.Pointer) bool {
+	_, found := b.arenaOf(ptr)
+	return found
+}
+
+// Return all arenas to the parent allocator
+//
+// All memory allocated from this allocator becomes invalid. Any allocations
+// forwarded to the parent allocator are NOT freed
+func (b *BuddyAllocator) Destroy() {
+	for _, arena := range b.arenas {
+		b.parentAlloc.RawFree(unsafe.Pointer(unsafe.SliceData(arena.mem)), b.arenaSize)
+	}
+	b.arenas = b.arenas[:0]
+	clear(b.freeLists)
+}
+
+var _ FallibleAllocator = (*BuddyAllocator)(nil)
+var _ Owner = (*BuddyAllocator)(nil)
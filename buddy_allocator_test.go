@@ -0,0 +1,117 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+// Check that every arena of `b` has merged back into a single free block
+func checkBuddyFullyFree(t *testing.T, b *BuddyAllocator) {
+	t.Helper()
+	for order, head := range b.freeLists {
+		count := 0
+		for block := head; block != nil; block = block.next {
+			count += 1
+		}
+		exp := 0
+		if order == b.maxOrder {
+			exp = len(b.arenas)
+		}
+		if count != exp {
+			t.Errorf("\ntest case failed: free blocks not fully merged\nORDER: %d\nEXP: %d\nGOT: %d\n", order, exp, count)
+		}
+	}
+}
+
+func TestBuddyAllocator(t *testing.T) {
+	parent := NewGoAllocator()
+	b := NewBuddyAllocator(parent, PAGE_SIZE*4)
+	live := stressAllocator(t, b, 1, 10000, PAGE_SIZE*5, PAGE_SIZE)
+	freeTestBlocks(b, live)
+	checkBuddyFullyFree(t, b)
+	b.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestBuddyAllocatorOverAligned(t *testing.T) {
+	b := NewBuddyAllocator(NewGoAllocator(), PAGE_SIZE)
+	ptr, alloc_len := b.RawAlloc(16, 64)
+	if uintptr(ptr)&63 != 0 || alloc_len != 16 {
+		t.Errorf("\ntest case failed: over-aligned block\nADDR: 0x%x\nEXP LEN: 16\nGOT LEN: %d\n", uintptr(ptr), alloc_len)
+	}
+	// The rest of the 64-byte block must be reusable by other allocations
+	next, _ := b.RawAlloc(16, 16)
+	if uintptr(next) != uintptr(ptr)+16 {
+		t.Errorf("\ntest case failed: buddy of over-aligned block not reused\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(ptr)+16, uintptr(next))
+	}
+	b.RawFree(next, 16)
+	b.RawFree(ptr, 16)
+	checkBuddyFullyFree(t, b)
+	page, _ := b.RawAlloc(PAGE_SIZE, PAGE_SIZE)
+	if page == nil {
+		t.Errorf("\ntest case failed: whole arena not available after freeing an over-aligned block\n")
+	}
+	if _, _, err := b.TryRawAlloc(16, PAGE_SIZE*2); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: align greater than PAGE_SIZE\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+}
+
+func TestBuddyAllocatorBlockAlignment(t *testing.T) {
+	b := NewBuddyAllocator(NewGoAllocator(), PAGE_SIZE*8)
+	for size := uintptr(MIN_BUDDY_BLOCK_SIZE); size <= PAGE_SIZE*8; size *= 2 {
+		ptr, _ := b.RawAlloc(size, 1)
+		if align := min(size, PAGE_SIZE); uintptr(ptr)&(align-1) != 0 {
+			t.Errorf("\ntest case failed: block alignment\nSIZE: %d\nEXP ALIGN: %d\nADDR: 0x%x\n", size, align, uintptr(ptr))
+		}
+		b.RawFree(ptr, size)
+	}
+	checkBuddyFullyFree(t, b)
+}
+
+func TestBuddyAllocatorResizeInPlace(t *testing.T) {
+	b := NewBuddyAllocator(NewGoAllocator(), PAGE_SIZE)
+	a, _ := b.RawAlloc(16, 16)
+	if _, ok := b.RawResizeInPlace(a, 16, 100); !ok {
+		t.Errorf("\ntest case failed: grow into free buddies\n")
+	}
+	c, _ := b.RawAlloc(128, 16)
+	if uintptr(c) != uintptr(a)+128 {
+		t.Errorf("\ntest case failed: grown block not removed from the free lists\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(a)+128, uintptr(c))
+	}
+	if _, ok := b.RawResizeInPlace(a, 128, 256); ok {
+		t.Errorf("\ntest case failed: grew into a live buddy\n")
+	}
+	if _, ok := b.RawResizeInPlace(c, 128, 256); ok {
+		t.Errorf("\ntest case failed: grew an upper half\n")
+	}
+	if _, ok := b.RawResizeInPlace(a, 128, 20); !ok {
+		t.Errorf("\ntest case failed: shrink\n")
+	}
+	if _, ok := b.RawResizeInPlace(a, 32, PAGE_SIZE*2); ok {
+		t.Errorf("\ntest case failed: grew past an arena\n")
+	}
+	b.RawFree(a, 32)
+	b.RawFree(c, 128)
+	checkBuddyFullyFree(t, b)
+}
+
+func TestBuddyAllocatorForwarding(t *testing.T) {
+	parent := NewGoAllocator()
+	b := NewBuddyAllocator(parent, PAGE_SIZE)
+	large, large_len := b.RawAlloc(PAGE_SIZE*2, 8)
+	if b.Owns(large) || parent.LiveCount() != 1 {
+		t.Errorf("\ntest case failed: large request not forwarded\nOWNED: %v\nPARENT LIVE: %d\n", b.Owns(large), parent.LiveCount())
+	}
+	b.RawFree(large, large_len)
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: large allocation not returned to parent\nGOT: %d\n", parent.LiveCount())
+	}
+	var local uint64
+	expectPanic(t, "free of a pointer from another allocator", func() { b.RawFree(unsafe.Pointer(&local), 8) })
+	if b.Owns(unsafe.Pointer(&local)) {
+		t.Errorf("\ntest case failed: foreign pointer owned\n")
+	}
+}
@@ -0,0 +1,362 @@
+package go_manual_memory
+
+import (
+	"cmp"
+	"math/bits"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// The smallest size class handled by an `AllocatorCache`
+const MIN_CACHED_SIZE = 16
+
+// The largest size class handled by an `AllocatorCache`, larger requests
+// are forwarded to the parent of the `CachingAllocator`
+const MAX_CACHED_SIZE = 4096
+
+// The number of power-of-2 size classes between `MIN_CACHED_SIZE` and `MAX_CACHED_SIZE`
+const NUM_CACHE_SIZE_CLASSES = 9
+
+// The number of slots moved between an `AllocatorCache` and its `CachingAllocator` at once
+const CACHE_BATCH_SIZE = 32
+
+// The size of each block the `CachingAllocator` requests from its parent to split into slots
+const CACHE_BLOCK_SIZE = PAGE_SIZE * 8
+
+// An intrusive singly-linked list of free slots, stored inside the free memory itself
+type freeList struct {
+	head  unsafe.Pointer
+	count int
+}
+
+func (f *freeList) push(ptr unsafe.Pointer) {
+	storeUnsafePtr((*unsafe.Pointer)(ptr), f.head)
+	f.head = ptr
+	f.count += 1
+}
+
+func (f *freeList) pop() unsafe.Pointer {
+	ptr := f.head
+	f.head = *(*unsafe.Pointer)(ptr)
+	f.count -= 1
+	return ptr
+}
+
+// Split off and return up to `n` slots from the front of the list
+func (f *freeList) popBatch(n int) (batch freeList) {
+	if f.count <= n {
+		batch = *f
+		*f = freeList{}
+		return
+	}
+	batch.head = f.head
+	batch.count = n
+	tail := f.head
+	for range n - 1 {
+		tail = *(*unsafe.Pointer)(tail)
+	}
+	f.head = *(*unsafe.Pointer)(tail)
+	f.count -= n
+	storeUnsafePtr((*unsafe.Pointer)(tail), nil)
+	return
+}
+
+// Move all slots in `batch` to the front of the list
+func (f *freeList) pushBatch(batch freeList) {
+	if batch.count == 0 {
+		return
+	}
+	tail := batch.head
+	for *(*unsafe.Pointer)(tail) != nil {
+		tail = *(*unsafe.Pointer)(tail)
+	}
+	storeUnsafePtr((*unsafe.Pointer)(tail), f.head)
+	f.head = batch.head
+	f.count += batch.count
+}
+
+// Return the size class index for an allocation of `len` bytes
+func cacheSizeClass(len uintptr) int {
+	len = max(len, MIN_CACHED_SIZE)
+	return bits.Len(uint(len-1)) - bits.Len(uint(MIN_CACHED_SIZE-1))
+}
+
+func cacheClassSize(class int) uintptr {
+	return MIN_CACHED_SIZE << class
+}
+
+// This allocator is the shared 'central' half of a caching allocator, modeled after the
+// thread caches of tcmalloc. Each worker goroutine should create its own `AllocatorCache`
+// with `NewCache()` and allocate through that
+//
+// Small allocations (up to `MAX_CACHED_SIZE`) are served from per-cache free lists
+// without locking, and slots are moved between the caches and this central allocator
+// in batches of `CACHE_BATCH_SIZE`. Larger allocations are forwarded to the parent
+// allocator under a lock. Requests that fit in a size class but need more alignment
+// than the size class provides are also forwarded, and remembered so they can be
+// returned to the parent when freed
+//
+// The `CachingAllocator` is safe for concurrent use by multiple goroutines. Each
+// `AllocatorCache` is NOT, and must only be used by one goroutine at a time
+type CachingAllocator struct {
+	mu          sync.Mutex
+	parentAlloc Allocator
+	blocks      [][]byte
+	classes     [NUM_CACHE_SIZE_CLASSES]freeList
+	overAligned map[uintptr]struct{}
+	// The number of entries in `overAligned`, so caches can skip the lock when it is empty
+	overAlignedCount atomic.Int64
+	// A copy of `blocks` sorted by address, replaced (never modified) under the lock
+	// so caches can find the block holding a slot without locking
+	sortedBlocks atomic.Pointer[[][]byte]
+}
+
+// A per-worker front-end to a `CachingAllocator`
+//
+// NOT safe for concurrent use, each worker goroutine should have its own
+type AllocatorCache struct {
+	central *CachingAllocator
+	classes [NUM_CACHE_SIZE_CLASSES]freeList
+}
+
+// Create a new `CachingAllocator` that requests memory from the parent allocator
+func NewCachingAllocator(parent Allocator) *CachingAllocator {
+	return &CachingAllocator{
+		parentAlloc: parent,
+		blocks:      make([][]byte, 0),
+		overAligned: make(map[uintptr]struct{}),
+	}
+}
+
+// Create a new `AllocatorCache` for a single worker goroutine
+func (c *CachingAllocator) NewCache() *AllocatorCache {
+	return &AllocatorCache{
+		central: c,
+	}
+}
+
+// Split a new block from the parent into slots of the size class. Must be called with the lock held
+func (c *CachingAllocator) addNewBlock(class int) error {
+	slotSize := cacheClassSize(class)
+	ptr, alloc_len, err := TryRawAlloc(c.parentAlloc, CACHE_BLOCK_SIZE, MAX_CACHED_SIZE)
+	if err != nil {
+		return err
+	}
+	block := unsafe.Slice((*byte)(ptr), alloc_len)
+	c.blocks = append(c.blocks, block)
+	var sorted [][]byte
+	if old := c.sortedBlocks.Load(); old != nil {
+		sorted = *old
+	}
+	idx, _ := slices.BinarySearchFunc(sorted, uintptr(ptr), compareBlockStart)
+	sorted = slices.Insert(slices.Clip(sorted), idx, block)
+	c.sortedBlocks.Store(&sorted)
+	for offset := uintptr(0); offset+slotSize <= alloc_len; offset += slotSize {
+		c.classes[class].push(unsafe.Pointer(&block[offset]))
+	}
+	return nil
+}
+
+func compareBlockStart(block []byte, addr uintptr) int {
+	return cmp.Compare(uintptr(unsafe.Pointer(unsafe.SliceData(block))), addr)
+}
+
+// Return whether `ptr` is inside a block split into slots, without locking
+func (c *CachingAllocator) inBlock(ptr unsafe.Pointer) bool {
+	sorted := c.sortedBlocks.Load()
+	if sorted == nil {
+		return false
+	}
+	idx, found := slices.BinarySearchFunc(*sorted, uintptr(ptr), compareBlockStart)
+	if found {
+		return true
+	}
+	return idx > 0 && blockContains((*sorted)[idx-1], ptr)
+}
+
+func (c *CachingAllocator) takeBatch(class int) (freeList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.classes[class].count == 0 {
+		if err := c.addNewBlock(class); err != nil {
+			return freeList{}, err
+		}
+	}
+	return c.classes[class].popBatch(CACHE_BATCH_SIZE), nil
+}
+
+func (c *CachingAllocator) returnBatch(class int, batch freeList) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.classes[class].pushBatch(batch)
+}
+
+func (c *CachingAllocator) parentTryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return TryRawAlloc(c.parentAlloc, len, align)
+}
+
+// Forward a request that needs more alignment than its size class to the parent,
+// remembering the pointer so it is returned to the parent when freed
+func (c *CachingAllocator) parentTryRawAllocOverAligned(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ptr, alloc_len, err = TryRawAlloc(c.parentAlloc, len, align)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.overAligned[uintptr(ptr)] = struct{}{}
+	c.overAlignedCount.Add(1)
+	// Any larger length would be freed straight to the parent without forgetting `ptr`
+	return ptr, min(alloc_len, MAX_CACHED_SIZE), nil
+}
+
+// Return whether `ptr` is a cached-size allocation that was forwarded to the parent
+//
+// Slots inside a block are recognized without locking, the lock is only taken
+// for pointers from outside every block
+func (c *CachingAllocator) isOverAligned(ptr unsafe.Pointer) bool {
+	if c.overAlignedCount.Load() == 0 || c.inBlock(ptr) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, found := c.overAligned[uintptr(ptr)]
+	return found
+}
+
+// Free an allocation made by `parentTryRawAllocOverAligned()`, returning false if
+// `ptr` was not one
+func (c *CachingAllocator) parentRawFreeOverAligned(ptr unsafe.Pointer, len uintptr) bool {
+	if c.overAlignedCount.Load() == 0 || c.inBlock(ptr) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.overAligned[uintptr(ptr)]; !found {
+		return false
+	}
+	delete(c.overAligned, uintptr(ptr))
+	c.overAlignedCount.Add(-1)
+	c.parentAlloc.RawFree(ptr, len)
+	return true
+}
+
+func (c *CachingAllocator) parentRawFree(ptr unsafe.Pointer, len uintptr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.parentAlloc.RawFree(ptr, len)
+}
+
+func (c *CachingAllocator) parentRawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// Return all blocks to the parent allocator
+//
+// All memory allocated from this allocator or any of its caches becomes invalid,
+// and all caches MUST NOT be used again. Any allocations forwarded to the parent
+// allocator are NOT freed
+func (c *CachingAllocator) Destroy() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, block := range c.blocks {
+		c.parentAlloc.RawFree(unsafe.Pointer(unsafe.SliceData(block)), uintptr(cap(block)))
+	}
+	c.blocks = c.blocks[:0]
+	c.sortedBlocks.Store(nil)
+	c.classes = [NUM_CACHE_SIZE_CLASSES]freeList{}
+}
+
+// RawAlloc implements Allocator.
+func (a *AllocatorCache) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := a.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (a *AllocatorCache) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	if len > MAX_CACHED_SIZE {
+		return a.central.parentTryRawAlloc(len, align)
+	}
+	class := cacheSizeClass(len)
+	alloc_len = cacheClassSize(class)
+	if align > alloc_len {
+		return a.central.parentTryRawAllocOverAligned(len, align)
+	}
+	if a.classes[class].count == 0 {
+		batch, err := a.central.takeBatch(class)
+		if err != nil {
+			return nil, 0, err
+		}
+		a.classes[class] = batch
+	}
+	return a.classes[class].pop(), alloc_len, nil
+}
+
+// RawFree implements Allocator.
+func (a *AllocatorCache) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	if len > MAX_CACHED_SIZE {
+		a.central.parentRawFree(ptr, len)
+		return
+	}
+	if a.central.parentRawFreeOverAligned(ptr, len) {
+		return
+	}
+	class := cacheSizeClass(len)
+	a.classes[class].push(ptr)
+	if a.classes[class].count >= CACHE_BATCH_SIZE*2 {
+		a.central.returnBatch(class, a.classes[class].popBatch(CACHE_BATCH_SIZE))
+	}
+}
+
+// RawResizeInPlace implements Allocator.
+func (a *AllocatorCache) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if old_len > MAX_CACHED_SIZE {
+		if new_len <= MAX_CACHED_SIZE {
+			return ptr, false
+		}
+		return a.central.parentRawResizeInPlace(ptr, old_len, new_len)
+	}
+	if new_len > MAX_CACHED_SIZE {
+		return ptr, false
+	}
+	if a.central.isOverAligned(ptr) {
+		return a.central.parentRawResizeInPlace(ptr, old_len, new_len)
+	}
+	return ptr, cacheSizeClass(old_len) == cacheSizeClass(new_len)
+}
+
+// Owns implements Owner.
+//
+// Reports ownership of any memory allocated through any cache of the same `CachingAllocator`
+func (a *AllocatorCache) Owns(ptr unsafe.Pointer) bool {
+	return a.central.inBlock(ptr)
+}
+
+// Return all cached slots to the central `CachingAllocator`
+//
+// Should be called before a worker goroutine exits so its cached slots can be reused
+func (a *AllocatorCache) Flush() {
+	for class := range a.classes {
+		a.central.returnBatch(class, a.classes[class])
+		a.classes[class] = freeList{}
+	}
+}
+
+var _ FallibleAllocator = (*AllocatorCache)(nil)
+var _ Owner = (*AllocatorCache)(nil)
@@ -0,0 +1,155 @@
+package go_manual_memory
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachingAllocator(t *testing.T) {
+	parent := NewGoAllocator()
+	c := NewCachingAllocator(parent)
+	cache := c.NewCache()
+	live := stressAllocator(t, cache, 1, 10000, MAX_CACHED_SIZE*2, 256)
+	freeTestBlocks(cache, live)
+	cache.Flush()
+	c.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestCachingAllocatorConcurrent(t *testing.T) {
+	parent := NewGoAllocator()
+	c := NewCachingAllocator(parent)
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	// Blocks handed from each worker to the next, so slots are freed into a different cache
+	handoff := make([]chan []testBlock, workers)
+	for w := range workers {
+		handoff[w] = make(chan []testBlock, 1)
+	}
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache := c.NewCache()
+			live, err := runAllocatorStress(cache, int64(w), 2000, MAX_CACHED_SIZE+512, 128)
+			errs[w] = err
+			handoff[(w+1)%workers] <- live
+			freeTestBlocks(cache, <-handoff[w])
+			cache.Flush()
+		}()
+	}
+	wg.Wait()
+	for w, err := range errs {
+		if err != nil {
+			t.Errorf("\nWORKER: %d%v", w, err)
+		}
+	}
+	c.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestCachingAllocatorSizeClasses(t *testing.T) {
+	tests := []struct {
+		len   uintptr
+		class int
+	}{
+		{1, 0},
+		{MIN_CACHED_SIZE, 0},
+		{MIN_CACHED_SIZE + 1, 1},
+		{100, 3},
+		{MAX_CACHED_SIZE, NUM_CACHE_SIZE_CLASSES - 1},
+	}
+	for _, test := range tests {
+		if class := cacheSizeClass(test.len); class != test.class {
+			t.Errorf("\ntest case failed: size class\nLEN: %d\nEXP: %d\nGOT: %d\n", test.len, test.class, class)
+		}
+	}
+	cache := NewCachingAllocator(NewGoAllocator()).NewCache()
+	ptr, alloc_len := cache.RawAlloc(100, 8)
+	if alloc_len != 128 {
+		t.Errorf("\ntest case failed: alloc_len\nEXP: 128\nGOT: %d\n", alloc_len)
+	}
+	if _, ok := cache.RawResizeInPlace(ptr, 100, 128); !ok {
+		t.Errorf("\ntest case failed: resize within size class\n")
+	}
+	if _, ok := cache.RawResizeInPlace(ptr, 128, 129); ok {
+		t.Errorf("\ntest case failed: resize past size class\n")
+	}
+	cache.RawFree(ptr, 128)
+	if again, _ := cache.RawAlloc(128, 8); again != ptr {
+		t.Errorf("\ntest case failed: freed slot was not reused\nEXP: %p\nGOT: %p\n", ptr, again)
+	}
+}
+
+func TestCachingAllocatorForwarding(t *testing.T) {
+	parent := NewGoAllocator()
+	c := NewCachingAllocator(parent)
+	cache := c.NewCache()
+	small, _ := cache.RawAlloc(16, 16)
+	if !cache.Owns(small) {
+		t.Errorf("\ntest case failed: slot not owned\n")
+	}
+	large, large_len := cache.RawAlloc(MAX_CACHED_SIZE+1, 8)
+	if cache.Owns(large) || large_len < MAX_CACHED_SIZE+1 {
+		t.Errorf("\ntest case failed: large request not forwarded\nOWNED: %v\nLEN: %d\n", cache.Owns(large), large_len)
+	}
+	aligned, aligned_len, err := cache.TryRawAlloc(16, 256)
+	if err != nil {
+		t.Fatalf("\ntest case failed: over-aligned request\nGOT ERR: %v\n", err)
+	}
+	if uintptr(aligned)&255 != 0 || cache.Owns(aligned) || aligned_len > MAX_CACHED_SIZE {
+		t.Errorf("\ntest case failed: over-aligned request not forwarded\nADDR: 0x%x\nOWNED: %v\nLEN: %d\n", uintptr(aligned), cache.Owns(aligned), aligned_len)
+	}
+	if _, _, err := cache.TryRawAlloc(MAX_CACHED_SIZE, MAX_CACHED_SIZE*2); err != nil {
+		t.Errorf("\ntest case failed: request aligned past the largest size class\nGOT ERR: %v\n", err)
+	}
+	liveCount := parent.LiveCount()
+	// Free from a different cache, the central allocator must still recognize it
+	c.NewCache().RawFree(aligned, 16)
+	cache.RawFree(large, large_len)
+	if parent.LiveCount() != liveCount-2 {
+		t.Errorf("\ntest case failed: forwarded allocations not returned to parent\nEXP: %d\nGOT: %d\n", liveCount-2, parent.LiveCount())
+	}
+	if c.overAlignedCount.Load() != 1 || len(c.overAligned) != 1 {
+		t.Errorf("\ntest case failed: over-aligned allocation not forgotten\nGOT: %d\n", c.overAlignedCount.Load())
+	}
+	for range CACHE_BATCH_SIZE * 2 {
+		if next, _ := cache.RawAlloc(16, 16); next == aligned {
+			t.Fatalf("\ntest case failed: over-aligned allocation was pushed on a free list\n")
+		}
+	}
+}
+
+func TestCachingAllocatorFreeWithoutLock(t *testing.T) {
+	c := NewCachingAllocator(NewGoAllocator())
+	cache := c.NewCache()
+	slot, _ := cache.RawAlloc(16, 16)
+	aligned, _ := cache.RawAlloc(16, 256)
+	// Holding the lock makes any attempt to take it in the cache block until the
+	// deadline, while over-aligned allocations are live
+	c.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		cache.RawFree(slot, 16)
+		cache.RawResizeInPlace(slot, 16, 8)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("\ntest case failed: freeing a slot took the central lock\n")
+	}
+	c.mu.Unlock()
+	<-done
+	cache.RawFree(aligned, 16)
+	if c.overAlignedCount.Load() != 0 {
+		t.Errorf("\ntest case failed: over-aligned allocation not freed to the parent\nGOT: %d\n", c.overAlignedCount.Load())
+	}
+	c.Destroy()
+}
@@ -0,0 +1,235 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// The maximum number of stack frames recorded for each allocation by a `DebugAllocator`
+const DEBUG_STACK_DEPTH = 32
+
+// This allocator wraps any other `Allocator` and records every live allocation along with
+// the call stack that created it, so that leaks, double frees, and frees with the wrong
+// length can be found
+//
+// Problems are always recorded and can be retrieved with `Errors()` or `Report()`. If
+// `panicOnError` was set when created, the allocator will also panic as soon as one is found
+//
+// The `DebugAllocator` is safe for concurrent use if the wrapped allocator is
+type DebugAllocator struct {
+	mu           sync.Mutex
+	parentAlloc  Allocator
+	panicOnError bool
+	live         map[uintptr]debugRecord
+	freed        map[uintptr]debugRecord
+	errors       []error
+}
+
+type debugRecord struct {
+	len       uintptr
+	alloc_len uintptr
+	stack     []uintptr
+}
+
+// Create a new `DebugAllocator` that tracks all allocations made through the parent allocator
+func NewDebugAllocator(parent Allocator, panicOnError bool) *DebugAllocator {
+	return &DebugAllocator{
+		parentAlloc:  parent,
+		panicOnError: panicOnError,
+		live:         make(map[uintptr]debugRecord),
+		freed:        make(map[uintptr]debugRecord),
+		errors:       make([]error, 0),
+	}
+}
+
+func captureStack() []uintptr {
+	pcs := make([]uintptr, DEBUG_STACK_DEPTH)
+	// Skip runtime.Callers, captureStack, and the DebugAllocator method
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
+
+func formatStack(stack []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// Record a problem, panicking if requested. Must be called with the lock held
+func (d *DebugAllocator) fail(method string, format string, args ...any) {
+	err := fmt.Errorf("go_manual_memory: DebugAllocator.%s(): %s", method, fmt.Sprintf(format, args...))
+	d.errors = append(d.errors, err)
+	if d.panicOnError {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Check that a pointer and length passed back to the allocator match a live allocation.
+// Must be called with the lock held
+func (d *DebugAllocator) checkLive(method string, ptr unsafe.Pointer, len uintptr, stack []uintptr) (rec debugRecord, ok bool) {
+	addr := uintptr(ptr)
+	rec, ok = d.live[addr]
+	if !ok {
+		if freed, wasFreed := d.freed[addr]; wasFreed {
+			d.fail(method, "pointer 0x%x was already freed\nfreed at:\n%s\ncalled again at:\n%s", addr, formatStack(freed.stack), formatStack(stack))
+		} else {
+			d.fail(method, "pointer 0x%x was never allocated by this allocator\ncalled at:\n%s", addr, formatStack(stack))
+		}
+		return rec, false
+	}
+	if len < rec.len || len > rec.alloc_len {
+		d.fail(method, "pointer 0x%x was passed with len %d, but was allocated with len %d (alloc_len %d)\nallocated at:\n%s\ncalled at:\n%s", addr, len, rec.len, rec.alloc_len, formatStack(rec.stack), formatStack(stack))
+		return rec, false
+	}
+	return rec, true
+}
+
+// Record a new live allocation
+func (d *DebugAllocator) track(ptr unsafe.Pointer, len uintptr, alloc_len uintptr, stack []uintptr) {
+	if ptr == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.freed, uintptr(ptr))
+	d.live[uintptr(ptr)] = debugRecord{
+		len:       len,
+		alloc_len: alloc_len,
+		stack:     stack,
+	}
+}
+
+// RawAlloc implements Allocator.
+func (d *DebugAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	stack := captureStack()
+	ptr, alloc_len = d.parentAlloc.RawAlloc(len, align)
+	d.track(ptr, len, alloc_len, stack)
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (d *DebugAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	stack := captureStack()
+	ptr, alloc_len, err = TryRawAlloc(d.parentAlloc, len, align)
+	if err != nil {
+		return nil, 0, err
+	}
+	d.track(ptr, len, alloc_len, stack)
+	return
+}
+
+// RawFree implements Allocator.
+//
+// Invalid frees are recorded as errors and NOT passed to the parent allocator
+func (d *DebugAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	stack := captureStack()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.checkLive("RawFree", ptr, len, stack); !ok {
+		return
+	}
+	delete(d.live, uintptr(ptr))
+	d.freed[uintptr(ptr)] = debugRecord{
+		len:   len,
+		stack: stack,
+	}
+	d.parentAlloc.RawFree(ptr, len)
+}
+
+// RawResizeInPlace implements Allocator.
+func (d *DebugAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	stack := captureStack()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rec, ok := d.checkLive("RawResizeInPlace", ptr, old_len, stack)
+	if !ok {
+		return ptr, false
+	}
+	newPtr, success = d.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	if !success {
+		return
+	}
+	delete(d.live, uintptr(ptr))
+	rec.len = new_len
+	rec.alloc_len = new_len
+	d.live[uintptr(newPtr)] = rec
+	return
+}
+
+// Owns implements Owner.
+//
+// Only allocations that are currently live are owned
+func (d *DebugAllocator) Owns(ptr unsafe.Pointer) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, found := d.live[uintptr(ptr)]
+	return found
+}
+
+// Return the number of allocations that have not yet been freed
+func (d *DebugAllocator) LiveCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.live)
+}
+
+// Return all double frees, invalid frees, and length mismatches found so far
+func (d *DebugAllocator) Errors() []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]error(nil), d.errors...)
+}
+
+// Return an error describing every allocation that has not yet been freed,
+// or nil if there are none
+func (d *DebugAllocator) CheckLeaks() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.live) == 0 {
+		return nil
+	}
+	addrs := make([]uintptr, 0, len(d.live))
+	for addr := range d.live {
+		addrs = append(addrs, addr)
+	}
+	slices.Sort(addrs)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "go_manual_memory: DebugAllocator.CheckLeaks(): %d allocations were never freed", len(d.live))
+	for _, addr := range addrs {
+		rec := d.live[addr]
+		fmt.Fprintf(&sb, "\n\npointer 0x%x (len %d, alloc_len %d) allocated at:\n%s", addr, rec.len, rec.alloc_len, formatStack(rec.stack))
+	}
+	return errors.New(sb.String())
+}
+
+// Return a human-readable report of all errors found so far and all leaked allocations
+func (d *DebugAllocator) Report() string {
+	var sb strings.Builder
+	errs := d.Errors()
+	fmt.Fprintf(&sb, "DebugAllocator: %d errors, %d live allocations\n", len(errs), d.LiveCount())
+	for _, err := range errs {
+		fmt.Fprintf(&sb, "\n%s\n", err)
+	}
+	if err := d.CheckLeaks(); err != nil {
+		fmt.Fprintf(&sb, "\n%s\n", err)
+	}
+	return sb.String()
+}
+
+var _ FallibleAllocator = (*DebugAllocator)(nil)
+var _ Owner = (*DebugAllocator)(nil)
@@ -0,0 +1,103 @@
+package go_manual_memory
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestDebugAllocator(t *testing.T) {
+	d := NewDebugAllocator(NewGoAllocator(), true)
+	live := stressAllocator(t, d, 1, 5000, 512, 64)
+	if d.LiveCount() != len(live) {
+		t.Errorf("\ntest case failed: live count\nEXP: %d\nGOT: %d\n", len(live), d.LiveCount())
+	}
+	freeTestBlocks(d, live)
+	if err := d.CheckLeaks(); err != nil {
+		t.Errorf("\ntest case failed: leak after freeing everything\nGOT ERR: %v\n", err)
+	}
+	if errs := d.Errors(); len(errs) != 0 {
+		t.Errorf("\ntest case failed: errors for valid use\nGOT: %v\n", errs)
+	}
+}
+
+func TestDebugAllocatorConcurrent(t *testing.T) {
+	d := NewDebugAllocator(NewGoAllocator(), true)
+	stressAllocatorConcurrent(t, d, 8, 512, 64)
+	if err := d.CheckLeaks(); err != nil {
+		t.Errorf("\ntest case failed: leak after freeing everything\nGOT ERR: %v\n", err)
+	}
+}
+
+func TestDebugAllocatorErrors(t *testing.T) {
+	parent := NewGoAllocator()
+	d := NewDebugAllocator(parent, false)
+	ptr, _ := d.RawAlloc(64, 8)
+	d.RawFree(ptr, 64)
+	d.RawFree(ptr, 64)
+	var local uint64
+	d.RawFree(unsafe.Pointer(&local), 8)
+	ptr, alloc_len := d.RawAlloc(64, 8)
+	d.RawFree(ptr, 32)
+	d.RawFree(ptr, alloc_len+1)
+	if _, ok := d.RawResizeInPlace(ptr, 16, 64); ok {
+		t.Errorf("\ntest case failed: resize with the wrong length succeeded\n")
+	}
+	expErrors := []string{
+		"was already freed",
+		"was never allocated",
+		"was passed with len 32",
+		"was passed with len",
+		"was passed with len 16",
+	}
+	errs := d.Errors()
+	if len(errs) != len(expErrors) {
+		t.Fatalf("\ntest case failed: error count\nEXP: %d\nGOT: %d\n%v\n", len(expErrors), len(errs), errs)
+	}
+	for i, exp := range expErrors {
+		if !strings.Contains(errs[i].Error(), exp) {
+			t.Errorf("\ntest case failed: error message\nEXP CONTAINS: %s\nGOT: %v\n", exp, errs[i])
+		}
+	}
+	if parent.LiveCount() != 1 {
+		t.Errorf("\ntest case failed: invalid frees were passed to the parent\nEXP LIVE: 1\nGOT LIVE: %d\n", parent.LiveCount())
+	}
+	if !d.Owns(ptr) {
+		t.Errorf("\ntest case failed: live allocation not owned\n")
+	}
+	err := d.CheckLeaks()
+	if err == nil || !strings.Contains(err.Error(), "1 allocations were never freed") || !strings.Contains(err.Error(), "TestDebugAllocatorErrors") {
+		t.Errorf("\ntest case failed: leak report missing allocation or stack\nGOT ERR: %v\n", err)
+	}
+	if report := d.Report(); !strings.HasPrefix(report, "DebugAllocator: 5 errors, 1 live allocations") {
+		t.Errorf("\ntest case failed: report header\nGOT: %s\n", report)
+	}
+	d.RawFree(ptr, alloc_len)
+	if d.Owns(ptr) || d.CheckLeaks() != nil {
+		t.Errorf("\ntest case failed: allocation still live after a valid free\n")
+	}
+}
+
+func TestDebugAllocatorPanicOnError(t *testing.T) {
+	d := NewDebugAllocator(NewGoAllocator(), true)
+	ptr, _ := d.RawAlloc(64, 8)
+	d.RawFree(ptr, 64)
+	expectPanic(t, "double free", func() { d.RawFree(ptr, 64) })
+	if len(d.Errors()) != 1 {
+		t.Errorf("\ntest case failed: error not recorded before panic\nEXP: 1\nGOT: %d\n", len(d.Errors()))
+	}
+}
+
+func TestDebugAllocatorResizeInPlace(t *testing.T) {
+	d := NewDebugAllocator(NewArenaAllocator(NewGoAllocator(), PAGE_SIZE), true)
+	ptr, _ := d.RawAlloc(32, 8)
+	newPtr, ok := d.RawResizeInPlace(ptr, 32, 128)
+	if !ok || newPtr != ptr {
+		t.Fatalf("\ntest case failed: resize of last arena allocation\nOK: %v\n", ok)
+	}
+	expectPanic(t, "free with the old length after resize", func() { d.RawFree(ptr, 32) })
+	d.RawFree(ptr, 128)
+	if d.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: live count after free\nGOT: %d\n", d.LiveCount())
+	}
+}
@@ -0,0 +1,244 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"math/bits"
+	"unsafe"
+
+	ll "github.com/gabe-lee/go_list_like"
+)
+
+// The smallest capacity a `Deque[T]` allocates when it must grow
+const MIN_DEQUE_CAP = 8
+
+// The largest capacity a `Deque[T]` can have, since its capacity is always a power of 2
+const MAX_DEQUE_CAP = 1 << 31
+
+// A double-ended queue stored in a ring buffer from an `Allocator`, with O(1) pushes and
+// pops at both ends and O(1) indexed access
+//
+// The capacity is always a power of 2, so wrapping an index around the buffer is a single
+// mask. When full, the buffer is doubled with `ResizeCanMove()`, and any values that had
+// wrapped around to the start are moved after the old end so the values stay in order
+//
+// Like a `List[T]`, the deque caches the `Allocator` it was created with and owns its buffer
+//
+// The `Deque[T]` is NOT safe for concurrent use
+type Deque[T any] struct {
+	ptr   *T
+	head  uint32
+	len   uint32
+	cap   uint32
+	alloc Allocator
+}
+
+// Creates a new empty `Deque[T]` with room for at least `capacity` values before it
+// needs to grow, using the provided `Allocator`
+func CreateDeque[T any](capacity int, alloc Allocator) Deque[T] {
+	d, err := TryCreateDeque[T](capacity, alloc)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return d
+}
+
+// Like `CreateDeque()`, but returns an error instead of panicking if the
+// memory cannot be allocated
+func TryCreateDeque[T any](capacity int, alloc Allocator) (Deque[T], error) {
+	checkLen("TryCreateDeque()", capacity)
+	d := Deque[T]{
+		alloc: alloc,
+	}
+	err := d.tryGrowCap(capacity)
+	return d, err
+}
+
+func (d *Deque[T]) buffer() []T {
+	return unsafe.Slice(d.ptr, d.cap)
+}
+
+// Return the index in the buffer of the value at index `idx` in the deque
+func (d *Deque[T]) wrap(idx uint32) uint32 {
+	return (d.head + idx) & (d.cap - 1)
+}
+
+// Ensure the deque has room for at least `minCap` values, rounding
+// the new capacity up to a power of 2
+func (d *Deque[T]) tryGrowCap(minCap int) error {
+	if minCap <= d.Cap() {
+		return nil
+	}
+	checkLen("Deque[T] growth", minCap)
+	newCap := max(uint64(1)<<bits.Len64(uint64(minCap-1)), MIN_DEQUE_CAP)
+	if newCap > MAX_DEQUE_CAP {
+		panic(fmt.Sprintf("fatal: go_manual_memory: Deque[T] growth: capacity %d overflows the max capacity %d", minCap, uint64(MAX_DEQUE_CAP)))
+	}
+	oldCap := d.cap
+	var newMem []T
+	var err error
+	if oldCap == 0 {
+		newMem, err = TryAlloc[T](d.alloc, int(newCap))
+	} else {
+		newMem, err = TryResizeCanMove(d.alloc, d.buffer(), int(newCap))
+	}
+	if err != nil {
+		return err
+	}
+	d.ptr = unsafe.SliceData(newMem)
+	d.cap = clampCap(int(newCap))
+	// The capacity at least doubled, so whichever part of the wrapped values
+	// is smaller can be moved without overlapping the other
+	if d.head+d.len > oldCap {
+		buf := d.buffer()
+		wrapped := d.head + d.len - oldCap
+		if wrapped <= oldCap-d.head {
+			copy(buf[oldCap:], buf[:wrapped])
+		} else {
+			newHead := d.cap - (oldCap - d.head)
+			copy(buf[newHead:], buf[d.head:oldCap])
+			d.head = newHead
+		}
+	}
+	return nil
+}
+
+// Return the number of values in the deque
+//
+// Anologous to `len(slice)`
+func (d *Deque[T]) Len() int {
+	return int(d.len)
+}
+
+// Return the number of values the deque can hold before it must grow
+//
+// Anologous to `cap(slice)`
+func (d *Deque[T]) Cap() int {
+	return int(d.cap)
+}
+
+// Return a pointer to the value at index `idx` in the deque, where index 0 is the front
+func (d *Deque[T]) GetPtr(idx int) *T {
+	checkIndex("Deque[T].GetPtr()", idx, d.Len())
+	return &d.buffer()[d.wrap(uint32(idx))]
+}
+
+var _ ll.SliceLike[byte] = (*Deque[byte])(nil)
+
+// Ensure there is room to add at least `additional` more values without reallocating
+func (d *Deque[T]) Reserve(additional int) {
+	if err := d.TryReserve(additional); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `Reserve()`, but returns an error instead of panicking if the
+// memory cannot be allocated, in which case the deque is unchanged
+func (d *Deque[T]) TryReserve(additional int) error {
+	return d.tryGrowCap(d.Len() + additional)
+}
+
+// Add a value to the back of the deque, growing it if neccessary
+func (d *Deque[T]) PushBack(val T) {
+	if err := d.TryPushBack(val); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `PushBack()`, but returns an error instead of panicking if the deque
+// needs to grow and the memory cannot be allocated, in which case the deque is unchanged
+func (d *Deque[T]) TryPushBack(val T) error {
+	if err := d.tryGrowCap(d.Len() + 1); err != nil {
+		return err
+	}
+	d.buffer()[d.wrap(d.len)] = val
+	d.len += 1
+	return nil
+}
+
+// Add a value to the front of the deque, growing it if neccessary
+func (d *Deque[T]) PushFront(val T) {
+	if err := d.TryPushFront(val); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `PushFront()`, but returns an error instead of panicking if the deque
+// needs to grow and the memory cannot be allocated, in which case the deque is unchanged
+func (d *Deque[T]) TryPushFront(val T) error {
+	if err := d.tryGrowCap(d.Len() + 1); err != nil {
+		return err
+	}
+	d.head = (d.head - 1) & (d.cap - 1)
+	d.buffer()[d.head] = val
+	d.len += 1
+	return nil
+}
+
+// Remove and return the value at the back of the deque, or return
+// false if the deque is empty
+func (d *Deque[T]) PopBack() (val T, ok bool) {
+	if d.len == 0 {
+		return val, false
+	}
+	d.len -= 1
+	return d.buffer()[d.wrap(d.len)], true
+}
+
+// Remove and return the value at the front of the deque, or return
+// false if the deque is empty
+func (d *Deque[T]) PopFront() (val T, ok bool) {
+	if d.len == 0 {
+		return val, false
+	}
+	val = d.buffer()[d.head]
+	d.head = d.wrap(1)
+	d.len -= 1
+	return val, true
+}
+
+// Return the values of the deque, in order, as the two contiguous regions of the ring
+// buffer they occupy. `second` is empty unless the values wrap around the end of the buffer
+//
+// The `Deque[T]` retains ownership of the data. Both sub-slices become INVALID after
+// the next push, pop, or other change to the deque
+func (d *Deque[T]) Segments() (first, second SubSlice[T]) {
+	if d.len == 0 {
+		return
+	}
+	buf := d.buffer()
+	firstLen := min(d.len, d.cap-d.head)
+	first = SubSlice[T]{
+		ptr: &buf[d.head],
+		len: firstLen,
+		cap: firstLen,
+	}
+	if secondLen := d.len - firstLen; secondLen > 0 {
+		second = SubSlice[T]{
+			ptr: &buf[0],
+			len: secondLen,
+			cap: secondLen,
+		}
+	}
+	return
+}
+
+// Remove all values from the deque, keeping the capacity
+func (d *Deque[T]) Clear() {
+	d.head = 0
+	d.len = 0
+}
+
+// Destroy this `Deque[T]`, returning the memory to the cached `Allocator`
+//
+// The caller MUST ensure the cached `Allocator` is the exact one originally used to
+// create the deque
+func (d *Deque[T]) Destroy() {
+	if d.cap > 0 {
+		Free(d.alloc, d.buffer())
+	}
+	d.ptr = nil
+	d.head = 0
+	d.len = 0
+	d.cap = 0
+	d.alloc = nil
+}
@@ -0,0 +1,179 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func checkDequeValues(t *testing.T, what string, d *Deque[int], exp []int) {
+	t.Helper()
+	got := make([]int, 0, d.Len())
+	for i := range d.Len() {
+		got = append(got, *d.GetPtr(i))
+	}
+	segments := make([]int, 0, d.Len())
+	first, second := d.Segments()
+	for _, segment := range []SubSlice[int]{first, second} {
+		segments = append(segments, segment.GoSlice()...)
+	}
+	if !slices.Equal(got, exp) || !slices.Equal(segments, exp) {
+		t.Fatalf("\ntest case failed: %s\nEXP: %v\nGOT: %v\nGOT SEGMENTS: %v\n", what, exp, got, segments)
+	}
+}
+
+func TestDeque(t *testing.T) {
+	parent := NewGoAllocator()
+	d := CreateDeque[int](0, parent)
+	exp := make([]int, 0)
+	r := rand.New(rand.NewSource(1))
+	for i := range 20000 {
+		switch r.Intn(5) {
+		case 0, 1:
+			d.PushBack(i)
+			exp = append(exp, i)
+		case 2:
+			d.PushFront(i)
+			exp = slices.Insert(exp, 0, i)
+		case 3:
+			val, ok := d.PopBack()
+			if ok != (len(exp) > 0) || (ok && val != exp[len(exp)-1]) {
+				t.Fatalf("\ntest case failed: PopBack()\nGOT: %d, %v\n", val, ok)
+			}
+			if ok {
+				exp = exp[:len(exp)-1]
+			}
+		default:
+			val, ok := d.PopFront()
+			if ok != (len(exp) > 0) || (ok && val != exp[0]) {
+				t.Fatalf("\ntest case failed: PopFront()\nGOT: %d, %v\n", val, ok)
+			}
+			if ok {
+				exp = exp[1:]
+			}
+		}
+		if i%100 == 0 {
+			checkDequeValues(t, "random pushes and pops", &d, exp)
+		}
+	}
+	checkDequeValues(t, "random pushes and pops", &d, exp)
+	d.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestDequeGrowthWrapped(t *testing.T) {
+	tests := []struct {
+		name  string
+		front int
+		back  int
+	}{
+		// Fewer values wrapped past the end of the buffer than before it, so they move after the old end
+		{"short wrapped part", 2, 6},
+		// More values wrapped than not, so the values before the end move to the end of the new buffer
+		{"long wrapped part", 6, 2},
+	}
+	for _, test := range tests {
+		d := CreateDeque[int](MIN_DEQUE_CAP, NewGoAllocator())
+		exp := make([]int, 0)
+		for i := range test.back {
+			d.PushBack(i)
+			exp = append(exp, i)
+		}
+		for i := range test.front {
+			d.PushFront(-i - 1)
+			exp = slices.Insert(exp, 0, -i-1)
+		}
+		if d.Cap() != MIN_DEQUE_CAP {
+			t.Fatalf("\ntest case failed: %s: deque grew before it was full\nGOT: %d\n", test.name, d.Cap())
+		}
+		if _, second := d.Segments(); second.Len() == 0 {
+			t.Fatalf("\ntest case failed: %s: values did not wrap\n", test.name)
+		}
+		d.PushBack(100)
+		exp = append(exp, 100)
+		if d.Cap() != MIN_DEQUE_CAP*2 {
+			t.Errorf("\ntest case failed: %s: growth\nEXP: %d\nGOT: %d\n", test.name, MIN_DEQUE_CAP*2, d.Cap())
+		}
+		checkDequeValues(t, test.name, &d, exp)
+		d.Reserve(100)
+		checkDequeValues(t, test.name+" after Reserve()", &d, exp)
+		if d.Cap() != 128 {
+			t.Errorf("\ntest case failed: %s: Reserve() rounds to a power of 2\nEXP: 128\nGOT: %d\n", test.name, d.Cap())
+		}
+		d.Destroy()
+	}
+}
+
+func TestDequeOperations(t *testing.T) {
+	d := CreateDeque[int](0, NewGoAllocator())
+	if _, ok := d.PopBack(); ok {
+		t.Errorf("\ntest case failed: PopBack() of an empty deque\n")
+	}
+	if _, ok := d.PopFront(); ok {
+		t.Errorf("\ntest case failed: PopFront() of an empty deque\n")
+	}
+	if first, second := d.Segments(); first.Len() != 0 || second.Len() != 0 {
+		t.Errorf("\ntest case failed: Segments() of an empty deque\n")
+	}
+	d.PushBack(1)
+	d.PushFront(0)
+	*d.GetPtr(1) = 10
+	checkDequeValues(t, "GetPtr() write", &d, []int{0, 10})
+	capBefore := d.Cap()
+	d.Clear()
+	if d.Len() != 0 || d.Cap() != capBefore {
+		t.Errorf("\ntest case failed: Clear()\nLEN: %d\nCAP: %d\n", d.Len(), d.Cap())
+	}
+	d.PushFront(5)
+	checkDequeValues(t, "PushFront() after Clear()", &d, []int{5})
+	if SAFETY_CHECKS {
+		expectPanic(t, "GetPtr() past the length", func() { d.GetPtr(1) })
+		expectPanic(t, "GetPtr() at a negative index", func() { d.GetPtr(-1) })
+		expectPanic(t, "CreateDeque() with negative capacity", func() { CreateDeque[int](-1, NewGoAllocator()) })
+	}
+	if bits.UintSize == 64 {
+		// Converted at runtime, since the constant would overflow `int` on 32-bit platforms
+		tooLarge := uint64(MAX_DEQUE_CAP) + 1
+		expectPanic(t, "Reserve() past MAX_DEQUE_CAP", func() { d.Reserve(int(tooLarge)) })
+	}
+	d.Destroy()
+	if d.Len() != 0 || d.Cap() != 0 {
+		t.Errorf("\ntest case failed: Destroy() did not reset the deque\n")
+	}
+}
+
+func TestDequeErrors(t *testing.T) {
+	parent := NewGoAllocator()
+	alloc := failingAllocator{parent, 8 * MIN_DEQUE_CAP}
+	if _, err := TryCreateDeque[uint64](MIN_DEQUE_CAP+1, alloc); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: TryCreateDeque() over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	d, err := TryCreateDeque[uint64](0, alloc)
+	if err != nil {
+		t.Fatalf("\ntest case failed: TryCreateDeque()\nGOT ERR: %v\n", err)
+	}
+	for i := range uint64(MIN_DEQUE_CAP) {
+		d.PushFront(i)
+	}
+	for _, try := range []func() error{
+		func() error { return d.TryPushBack(100) },
+		func() error { return d.TryPushFront(100) },
+		func() error { return d.TryReserve(1) },
+	} {
+		if err := try(); !errors.Is(err, ErrOutOfMemory) {
+			t.Errorf("\ntest case failed: growth over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+		}
+		if d.Len() != MIN_DEQUE_CAP || d.Cap() != MIN_DEQUE_CAP || *d.GetPtr(0) != MIN_DEQUE_CAP-1 {
+			t.Errorf("\ntest case failed: deque changed by failed growth\nLEN: %d\nCAP: %d\n", d.Len(), d.Cap())
+		}
+	}
+	expectPanic(t, "PushBack() over the limit", func() { d.PushBack(100) })
+	d.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", parent.LiveCount())
+	}
+}
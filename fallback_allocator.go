@@ -0,0 +1,72 @@
+package go_manual_memory
+
+import "unsafe"
+
+// This allocator tries to allocate from `Primary` first, and only uses `Secondary` when the
+// primary fails. Frees and resizes are routed back to whichever allocator owns the memory,
+// which is why `Primary` MUST be an `Owner`
+//
+// The primary should be a `FallibleAllocator` with a fixed capacity, like an `ArenaAllocator`
+// over a `LimitAllocator`, so that it can report failure without panicking. It should not
+// forward any requests to a parent, since those allocations are not owned by it. Create one
+// directly with a struct literal or with `NewFallbackAllocator()`
+//
+// The `FallbackAllocator` is only safe for concurrent use if both wrapped allocators are
+type FallbackAllocator struct {
+	Primary   Owner
+	Secondary Allocator
+}
+
+// Create a new `FallbackAllocator` that uses `secondary` whenever `primary` fails
+func NewFallbackAllocator(primary Owner, secondary Allocator) *FallbackAllocator {
+	return &FallbackAllocator{
+		Primary:   primary,
+		Secondary: secondary,
+	}
+}
+
+// RawAlloc implements Allocator.
+func (f *FallbackAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := f.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (f *FallbackAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	ptr, alloc_len, err = TryRawAlloc(f.Primary, len, align)
+	if err == nil {
+		return
+	}
+	return TryRawAlloc(f.Secondary, len, align)
+}
+
+// RawFree implements Allocator.
+func (f *FallbackAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	if f.Primary.Owns(ptr) {
+		f.Primary.RawFree(ptr, len)
+		return
+	}
+	f.Secondary.RawFree(ptr, len)
+}
+
+// RawResizeInPlace implements Allocator.
+func (f *FallbackAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if f.Primary.Owns(ptr) {
+		return f.Primary.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	return f.Secondary.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// Owns implements Owner.
+func (f *FallbackAllocator) Owns(ptr unsafe.Pointer) bool {
+	return f.Primary.Owns(ptr) || Owns(f.Secondary, ptr)
+}
+
+var _ FallibleAllocator = (*FallbackAllocator)(nil)
+var _ Owner = (*FallbackAllocator)(nil)
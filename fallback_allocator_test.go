@@ -0,0 +1,58 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestFallbackAllocator(t *testing.T) {
+	primary := NewLimitAllocator(NewArenaAllocator(NewGoAllocator(), PAGE_SIZE), 1000)
+	secondary := NewGoAllocator()
+	f := NewFallbackAllocator(primary, secondary)
+	a, _ := f.RawAlloc(600, 8)
+	if !primary.Owns(a) || secondary.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: first allocation not from primary\nPRIMARY: %v\nSECONDARY: %d\n", primary.Owns(a), secondary.LiveCount())
+	}
+	b, b_len := f.RawAlloc(600, 8)
+	if primary.Owns(b) || !secondary.Owns(b) {
+		t.Errorf("\ntest case failed: allocation over the primary's limit not from secondary\nPRIMARY: %v\nSECONDARY: %v\n", primary.Owns(b), secondary.Owns(b))
+	}
+	if !f.Owns(a) || !f.Owns(b) {
+		t.Errorf("\ntest case failed: allocations not owned\nA: %v\nB: %v\n", f.Owns(a), f.Owns(b))
+	}
+	var local [16]byte
+	if f.Owns(unsafe.Pointer(&local)) {
+		t.Errorf("\ntest case failed: foreign pointer is owned\n")
+	}
+	if _, ok := f.RawResizeInPlace(a, 600, 900); !ok || primary.BytesInUse() != 900 {
+		t.Errorf("\ntest case failed: resize not routed to primary\nOK: %v\nBYTES: %d\n", ok, primary.BytesInUse())
+	}
+	if _, ok := f.RawResizeInPlace(b, 600, b_len); !ok {
+		t.Errorf("\ntest case failed: resize not routed to secondary\n")
+	}
+	f.RawFree(b, b_len)
+	if secondary.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: free not routed to secondary\nGOT: %d\n", secondary.LiveCount())
+	}
+	f.RawFree(a, 900)
+	if primary.BytesInUse() != 0 {
+		t.Errorf("\ntest case failed: free not routed to primary\nGOT: %d\n", primary.BytesInUse())
+	}
+	f.RawFree(nil, 16)
+}
+
+func TestFallbackAllocatorErrors(t *testing.T) {
+	primary := NewLimitAllocator(NewArenaAllocator(NewGoAllocator(), PAGE_SIZE), 100)
+	f := NewFallbackAllocator(primary, failingAllocator{NewGoAllocator(), 200})
+	if _, _, err := f.TryRawAlloc(150, 8); err != nil {
+		t.Errorf("\ntest case failed: fallback to secondary\nGOT ERR: %v\n", err)
+	}
+	if _, _, err := f.TryRawAlloc(300, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: both allocators fail\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	expectPanic(t, "RawAlloc() when both allocators fail", func() { f.RawAlloc(300, 8) })
+	if primary.BytesInUse() != 0 {
+		t.Errorf("\ntest case failed: failed requests changed the primary\nGOT: %d\n", primary.BytesInUse())
+	}
+}
@@ -0,0 +1,390 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// Identifies a file managed by a `FileAllocator`
+const FILE_ALLOCATOR_MAGIC = "GOMMHEAP"
+
+// Incremented whenever the layout of a `FileAllocator` file changes
+const FILE_ALLOCATOR_VERSION = 1
+
+// Every block in a `FileAllocator` file is a multiple of this size
+// and aligned to at least this size
+const MIN_FILE_BLOCK_SIZE = 16
+
+// The offset of the first allocatable byte in a `FileAllocator` file
+const FILE_HEADER_SIZE = 64
+
+// Stored at offset 0 of every `FileAllocator` file
+type fileHeader struct {
+	magic    [8]byte
+	version  uint32
+	addrSize uint32
+	fileLen  uint64
+	freeHead uint64
+	root     uint64
+	// Only used by a `SharedAllocator`, 0 when unlocked
+	lock uint32
+}
+
+// Stored at the start of every free block, forming a list sorted by offset
+type fileFreeBlock struct {
+	len  uint64
+	next uint64
+}
+
+// This allocator manages the memory of a file mapped with `mmap()`, so that data
+// allocated from it persists and can be used again by a later process with no
+// serialization step
+//
+// Allocations are addressed by their offset `A` into the file rather than a pointer, so
+// they remain valid no matter where the file is mapped. Use `Expand()` to get a pointer
+// to the data at an offset. Offset 0 is never a valid allocation, analogous to `nil`
+//
+// The free list is stored entirely inside the file: its head is kept in the file header,
+// and each free block stores its own length and the offset of the next free block
+//
+// The file is grown automatically when it runs out of space, which moves the mapping.
+// Any pointers returned from `Expand()` become INVALID after any allocation, while
+// offsets remain valid. Data stored in the file must therefore only refer to other data
+// by offset, never by pointer
+//
+// The `FileAllocator` is NOT safe for concurrent use
+type FileAllocator[A Address] struct {
+	file     *os.File
+	data     []byte
+	fixedLen bool
+}
+
+// Open the file at `path` as a `FileAllocator`, creating it with at least `initialLen`
+// bytes if it does not exist
+//
+// An existing file MUST have been created by a `FileAllocator` with the same address type `A`
+func OpenFileAllocator[A Address](path string, initialLen uintptr) (*FileAllocator[A], error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return initFileAllocator[A](file, initialLen)
+	}
+	return mapFileAllocator[A](file, uintptr(info.Size()))
+}
+
+// Size an empty file to at least `initialLen` bytes, map it, and write a new header and free list
+//
+// The header version is written last, so another process mapping the same file can
+// tell when it is ready. The file is closed if an error is returned
+func initFileAllocator[A Address](file *os.File, initialLen uintptr) (*FileAllocator[A], error) {
+	fileLen := alignForward(max(initialLen, FILE_HEADER_SIZE+MIN_FILE_BLOCK_SIZE), PAGE_SIZE)
+	if uint64(fileLen-1) > maxAddress[A]() {
+		file.Close()
+		return nil, fmt.Errorf("go_manual_memory: OpenFileAllocator(): initial length %d is greater than max address %d", fileLen, maxAddress[A]())
+	}
+	if err := file.Truncate(int64(fileLen)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	f := &FileAllocator[A]{
+		file: file,
+	}
+	if err := f.mapFile(fileLen); err != nil {
+		file.Close()
+		return nil, err
+	}
+	head := f.header()
+	copy(head.magic[:], FILE_ALLOCATOR_MAGIC)
+	head.addrSize = uint32(unsafe.Sizeof(A(0)))
+	head.fileLen = uint64(fileLen)
+	f.releaseBlock(FILE_HEADER_SIZE, fileLen-FILE_HEADER_SIZE)
+	atomic.StoreUint32(&head.version, FILE_ALLOCATOR_VERSION)
+	return f, nil
+}
+
+// Map an existing file of `fileLen` bytes and verify its header.
+// The file is closed if an error is returned
+func mapFileAllocator[A Address](file *os.File, fileLen uintptr) (*FileAllocator[A], error) {
+	f := &FileAllocator[A]{
+		file: file,
+	}
+	if err := f.mapFile(fileLen); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := f.checkHeader(fileLen); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileAllocator[A]) checkHeader(fileLen uintptr) error {
+	if fileLen < FILE_HEADER_SIZE {
+		return fmt.Errorf("go_manual_memory: OpenFileAllocator(): file %s is too small to be a FileAllocator file", f.file.Name())
+	}
+	head := f.header()
+	if string(head.magic[:]) != FILE_ALLOCATOR_MAGIC {
+		return fmt.Errorf("go_manual_memory: OpenFileAllocator(): file %s is not a FileAllocator file", f.file.Name())
+	}
+	if head.version != FILE_ALLOCATOR_VERSION {
+		return fmt.Errorf("go_manual_memory: OpenFileAllocator(): file %s has version %d, expected %d", f.file.Name(), head.version, FILE_ALLOCATOR_VERSION)
+	}
+	if head.addrSize != uint32(unsafe.Sizeof(A(0))) {
+		return fmt.Errorf("go_manual_memory: OpenFileAllocator(): file %s uses %d-byte addresses, expected %d", f.file.Name(), head.addrSize, unsafe.Sizeof(A(0)))
+	}
+	if head.fileLen != uint64(fileLen) {
+		return fmt.Errorf("go_manual_memory: OpenFileAllocator(): file %s has length %d, but header records %d", f.file.Name(), fileLen, head.fileLen)
+	}
+	return nil
+}
+
+func (f *FileAllocator[A]) mapFile(fileLen uintptr) error {
+	data, err := syscall.Mmap(int(f.file.Fd()), 0, int(fileLen), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	f.data = data
+	return nil
+}
+
+func (f *FileAllocator[A]) header() *fileHeader {
+	return (*fileHeader)(unsafe.Pointer(&f.data[0]))
+}
+
+func (f *FileAllocator[A]) block(off uint64) *fileFreeBlock {
+	return (*fileFreeBlock)(unsafe.Pointer(&f.data[off]))
+}
+
+// Return the largest offset representable by the address type `A`
+func maxAddress[A Address]() uint64 {
+	return uint64(^A(0))
+}
+
+// Grow the file by at least `need` bytes and remap it, adding the new space to the free list
+func (f *FileAllocator[A]) grow(need uintptr) error {
+	if f.fixedLen {
+		return fmt.Errorf("%w: FileAllocator.grow(): file %s has a fixed length of %d bytes", ErrOutOfMemory, f.file.Name(), len(f.data))
+	}
+	oldLen := uintptr(len(f.data))
+	newLen := alignForward(max(oldLen*2, oldLen+need), PAGE_SIZE)
+	limit := uintptr(min(maxAddress[A](), uint64(math.MaxInt))) + 1
+	if newLen > limit {
+		newLen = limit & ^uintptr(PAGE_SIZE-1)
+	}
+	if newLen < oldLen+need {
+		return fmt.Errorf("%w: FileAllocator.grow(): file cannot grow by %d bytes without exceeding max address %d", ErrOutOfMemory, need, maxAddress[A]())
+	}
+	if err := f.file.Truncate(int64(newLen)); err != nil {
+		return fmt.Errorf("%w: FileAllocator.grow(): %w", ErrOutOfMemory, err)
+	}
+	// Map the grown file before unmapping the old mapping, so a failure leaves
+	// the allocator usable at its old length
+	oldData := f.data
+	if err := f.mapFile(newLen); err != nil {
+		f.file.Truncate(int64(oldLen))
+		return fmt.Errorf("%w: FileAllocator.grow(): %w", ErrOutOfMemory, err)
+	}
+	if err := syscall.Munmap(oldData); err != nil {
+		return err
+	}
+	f.header().fileLen = uint64(newLen)
+	f.releaseBlock(uint64(oldLen), newLen-oldLen)
+	return nil
+}
+
+// Insert a block into the free list, merging it with any free neighbors
+func (f *FileAllocator[A]) releaseBlock(off uint64, len uintptr) {
+	head := f.header()
+	prev := uint64(0)
+	next := head.freeHead
+	for next != 0 && next < off {
+		prev = next
+		next = f.block(next).next
+	}
+	blockLen := uint64(len)
+	if next != 0 && off+blockLen == next {
+		blockLen += f.block(next).len
+		next = f.block(next).next
+	}
+	if prev != 0 && prev+f.block(prev).len == off {
+		f.block(prev).len += blockLen
+		f.block(prev).next = next
+		return
+	}
+	*f.block(off) = fileFreeBlock{
+		len:  blockLen,
+		next: next,
+	}
+	if prev == 0 {
+		head.freeHead = off
+	} else {
+		f.block(prev).next = off
+	}
+}
+
+// Find the first free block that can hold `len` bytes at the requested alignment,
+// returning any unused space before and after it to the free list
+func (f *FileAllocator[A]) findFreeBlock(len, align uintptr) (off uint64, found bool) {
+	head := f.header()
+	prev := uint64(0)
+	cur := head.freeHead
+	for cur != 0 {
+		block := *f.block(cur)
+		start := uint64(alignForward(uintptr(cur), align))
+		pad := start - cur
+		if pad+uint64(len) <= block.len {
+			if prev == 0 {
+				head.freeHead = block.next
+			} else {
+				f.block(prev).next = block.next
+			}
+			if pad > 0 {
+				f.releaseBlock(cur, uintptr(pad))
+			}
+			if tail := block.len - pad - uint64(len); tail > 0 {
+				f.releaseBlock(start+uint64(len), uintptr(tail))
+			}
+			return start, true
+		}
+		prev = cur
+		cur = block.next
+	}
+	return 0, false
+}
+
+// Expand the offset into a pointer to the data in the current mapping
+//
+// The pointer becomes INVALID after any further allocation from this allocator
+func (f *FileAllocator[A]) Expand(off A) unsafe.Pointer {
+	if off == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&f.data[off])
+}
+
+// Return a Golang slice `[]T` of the `len` values stored at the offset
+//
+// The slice becomes INVALID after any further allocation from this allocator
+func ExpandFileSlice[T any, A Address](f *FileAllocator[A], off A, len int) []T {
+	return unsafe.Slice((*T)(f.Expand(off)), len)
+}
+
+// Return the offset stored as the 'root' of the file, which should refer to the data
+// a later process needs to find everything else stored in the file
+func (f *FileAllocator[A]) Root() A {
+	return A(f.header().root)
+}
+
+// Set the offset stored as the 'root' of the file
+func (f *FileAllocator[A]) SetRoot(off A) {
+	f.header().root = uint64(off)
+}
+
+// RawAlloc allocates `len` bytes from the file, returning the offset of the allocation
+func (f *FileAllocator[A]) RawAlloc(len uintptr, align uintptr) (off A, alloc_len uintptr) {
+	off, alloc_len, err := f.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// Like `RawAlloc()`, but returns an error instead of panicking if the
+// file cannot grow to hold the allocation
+func (f *FileAllocator[A]) TryRawAlloc(len uintptr, align uintptr) (off A, alloc_len uintptr, err error) {
+	if len == 0 {
+		return 0, 0, nil
+	}
+	align = max(align, MIN_FILE_BLOCK_SIZE)
+	alloc_len = alignForward(len, MIN_FILE_BLOCK_SIZE)
+	start, found := f.findFreeBlock(alloc_len, align)
+	if !found {
+		if err = f.grow(alloc_len + align); err != nil {
+			return 0, 0, err
+		}
+		if start, found = f.findFreeBlock(alloc_len, align); !found {
+			return 0, 0, fmt.Errorf("%w: FileAllocator.TryRawAlloc(): grown file cannot hold requested len %d (align %d)", ErrOutOfMemory, len, align)
+		}
+	}
+	return A(start), alloc_len, nil
+}
+
+// RawFree returns the `len` bytes at the offset to the free list
+func (f *FileAllocator[A]) RawFree(off A, len uintptr) {
+	if off == 0 || len == 0 {
+		return
+	}
+	f.releaseBlock(uint64(off), alignForward(len, MIN_FILE_BLOCK_SIZE))
+}
+
+// RawResizeInPlace attempts to grow or shrink the allocation at the offset without moving it
+func (f *FileAllocator[A]) RawResizeInPlace(off A, old_len uintptr, new_len uintptr) (newOff A, success bool) {
+	old_len = alignForward(old_len, MIN_FILE_BLOCK_SIZE)
+	new_len = alignForward(new_len, MIN_FILE_BLOCK_SIZE)
+	if new_len == old_len {
+		return off, true
+	}
+	if new_len < old_len {
+		f.releaseBlock(uint64(off)+uint64(new_len), old_len-new_len)
+		return off, true
+	}
+	need := uint64(new_len - old_len)
+	end := uint64(off) + uint64(old_len)
+	head := f.header()
+	prev := uint64(0)
+	cur := head.freeHead
+	for cur != 0 && cur < end {
+		prev = cur
+		cur = f.block(cur).next
+	}
+	if cur != end || f.block(cur).len < need {
+		return off, false
+	}
+	block := *f.block(cur)
+	if prev == 0 {
+		head.freeHead = block.next
+	} else {
+		f.block(prev).next = block.next
+	}
+	if block.len > need {
+		f.releaseBlock(end+need, uintptr(block.len-need))
+	}
+	return off, true
+}
+
+// Flush all changes to the file on disk
+func (f *FileAllocator[A]) Sync() error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&f.data[0])), uintptr(len(f.data)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Flush all changes to the file on disk, then unmap and close it
+//
+// All pointers returned from `Expand()` become invalid
+func (f *FileAllocator[A]) Close() error {
+	var errs []error
+	if f.data != nil {
+		errs = append(errs, f.Sync(), syscall.Munmap(f.data))
+		f.data = nil
+	}
+	errs = append(errs, f.file.Close())
+	return errors.Join(errs...)
+}
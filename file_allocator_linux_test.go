@@ -0,0 +1,156 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Check the free list of `f` is sorted, in bounds, and fully coalesced,
+// returning the total number of free bytes
+func checkFileFreeList[A Address](t *testing.T, f *FileAllocator[A]) uint64 {
+	t.Helper()
+	total := uint64(0)
+	prevEnd := uint64(0)
+	for off := f.header().freeHead; off != 0; off = f.block(off).next {
+		block := f.block(off)
+		if off < FILE_HEADER_SIZE || off+block.len > uint64(len(f.data)) || block.len%MIN_FILE_BLOCK_SIZE != 0 {
+			t.Fatalf("\ntest case failed: free block out of bounds\nOFFSET: %d\nLEN: %d\nFILE LEN: %d\n", off, block.len, len(f.data))
+		}
+		if off <= prevEnd && prevEnd != 0 {
+			t.Fatalf("\ntest case failed: free blocks unsorted or not coalesced\nPREV END: %d\nOFFSET: %d\n", prevEnd, off)
+		}
+		prevEnd = off + block.len
+		total += block.len
+	}
+	return total
+}
+
+func TestFileAllocator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heap")
+	f, err := OpenFileAllocator[uint32](path, PAGE_SIZE)
+	if err != nil {
+		t.Fatalf("\ntest case failed: create\nGOT ERR: %v\n", err)
+	}
+	if free := checkFileFreeList(t, f); free != PAGE_SIZE-FILE_HEADER_SIZE {
+		t.Errorf("\ntest case failed: free bytes in new file\nEXP: %d\nGOT: %d\n", PAGE_SIZE-FILE_HEADER_SIZE, free)
+	}
+	offs := make([]uint32, 0, 100)
+	for i := range 100 {
+		off, alloc_len := f.RawAlloc(uintptr(i*7+1), 1<<(i%7))
+		if off == 0 || off%(1<<(i%7)) != 0 || alloc_len < uintptr(i*7+1) {
+			t.Fatalf("\ntest case failed: allocation\nOFFSET: %d\nALIGN: %d\nLEN: %d\n", off, 1<<(i%7), alloc_len)
+		}
+		ExpandFileSlice[byte](f, off, i*7+1)[i*7] = byte(i)
+		offs = append(offs, off)
+	}
+	if len(f.data) <= PAGE_SIZE {
+		t.Errorf("\ntest case failed: file did not grow\nLEN: %d\n", len(f.data))
+	}
+	for i, off := range offs {
+		if got := ExpandFileSlice[byte](f, off, i*7+1)[i*7]; got != byte(i) {
+			t.Fatalf("\ntest case failed: data lost after growth\nEXP: %d\nGOT: %d\n", i, got)
+		}
+	}
+	f.SetRoot(offs[99])
+	for i := 0; i < len(offs); i += 2 {
+		f.RawFree(offs[i], uintptr(i*7+1))
+	}
+	checkFileFreeList(t, f)
+	fileLen := len(f.data)
+	if err := f.Close(); err != nil {
+		t.Fatalf("\ntest case failed: close\nGOT ERR: %v\n", err)
+	}
+	f, err = OpenFileAllocator[uint32](path, 0)
+	if err != nil {
+		t.Fatalf("\ntest case failed: reopen\nGOT ERR: %v\n", err)
+	}
+	if f.Root() != offs[99] || len(f.data) != fileLen {
+		t.Errorf("\ntest case failed: reopened file\nEXP ROOT: %d\nGOT ROOT: %d\nEXP LEN: %d\nGOT LEN: %d\n", offs[99], f.Root(), fileLen, len(f.data))
+	}
+	for i := 1; i < len(offs); i += 2 {
+		if got := ExpandFileSlice[byte](f, offs[i], i*7+1)[i*7]; got != byte(i) {
+			t.Fatalf("\ntest case failed: data lost after reopen\nEXP: %d\nGOT: %d\n", i, got)
+		}
+		f.RawFree(offs[i], uintptr(i*7+1))
+	}
+	if free := checkFileFreeList(t, f); free != uint64(fileLen-FILE_HEADER_SIZE) {
+		t.Errorf("\ntest case failed: free bytes after freeing everything\nEXP: %d\nGOT: %d\n", fileLen-FILE_HEADER_SIZE, free)
+	}
+	if f.Expand(0) != nil {
+		t.Errorf("\ntest case failed: offset 0 did not expand to nil\n")
+	}
+	f.Close()
+}
+
+func TestFileAllocatorResizeInPlace(t *testing.T) {
+	f, err := OpenFileAllocator[uint32](filepath.Join(t.TempDir(), "heap"), PAGE_SIZE)
+	if err != nil {
+		t.Fatalf("\ntest case failed: create\nGOT ERR: %v\n", err)
+	}
+	defer f.Close()
+	a, _ := f.RawAlloc(32, 16)
+	b, _ := f.RawAlloc(32, 16)
+	if _, ok := f.RawResizeInPlace(a, 32, 48); ok {
+		t.Errorf("\ntest case failed: grew into a live neighbor\n")
+	}
+	if _, ok := f.RawResizeInPlace(b, 32, 1000); !ok {
+		t.Errorf("\ntest case failed: grow into free space\n")
+	}
+	if _, ok := f.RawResizeInPlace(b, 1000, 17); !ok {
+		t.Errorf("\ntest case failed: shrink\n")
+	}
+	if _, ok := f.RawResizeInPlace(a, 32, 30); !ok {
+		t.Errorf("\ntest case failed: resize within the same block size\n")
+	}
+	if _, ok := f.RawResizeInPlace(b, 32, PAGE_SIZE); ok {
+		t.Errorf("\ntest case failed: grew past the end of the file\n")
+	}
+	f.RawFree(a, 32)
+	f.RawFree(b, 32)
+	if free := checkFileFreeList(t, f); free != PAGE_SIZE-FILE_HEADER_SIZE {
+		t.Errorf("\ntest case failed: free bytes after freeing everything\nEXP: %d\nGOT: %d\n", PAGE_SIZE-FILE_HEADER_SIZE, free)
+	}
+}
+
+func TestFileAllocatorErrors(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenFileAllocator[uint16](filepath.Join(dir, "small"), PAGE_SIZE)
+	if err != nil {
+		t.Fatalf("\ntest case failed: create\nGOT ERR: %v\n", err)
+	}
+	if _, _, err := f.TryRawAlloc(1<<16, 16); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: allocation past the max address\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if _, _, err := f.TryRawAlloc(PAGE_SIZE*4, 16); err != nil {
+		t.Errorf("\ntest case failed: allocation within the max address\nGOT ERR: %v\n", err)
+	}
+	expectPanic(t, "RawAlloc() past the max address", func() { f.RawAlloc(1<<16, 16) })
+	checkFileFreeList(t, f)
+	f.fixedLen = true
+	if _, _, err := f.TryRawAlloc(uintptr(len(f.data)), 16); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: allocation past a fixed length\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	f.Close()
+	if _, err := OpenFileAllocator[uint16](filepath.Join(dir, "large"), 1<<17); err == nil {
+		t.Errorf("\ntest case failed: initial length past the max address\n")
+	}
+	if _, err := OpenFileAllocator[uint32](filepath.Join(dir, "small"), 0); err == nil {
+		t.Errorf("\ntest case failed: reopen with a different address size\n")
+	}
+	other := filepath.Join(dir, "other")
+	os.WriteFile(other, make([]byte, PAGE_SIZE), 0o644)
+	if _, err := OpenFileAllocator[uint32](other, 0); err == nil {
+		t.Errorf("\ntest case failed: open a file without the magic header\n")
+	}
+	os.WriteFile(other, make([]byte, 8), 0o644)
+	if _, err := OpenFileAllocator[uint32](other, 0); err == nil {
+		t.Errorf("\ntest case failed: open a file smaller than the header\n")
+	}
+	if _, err := OpenFileAllocator[uint32](filepath.Join(dir, "missing", "heap"), 0); err == nil {
+		t.Errorf("\ntest case failed: open in a missing directory\n")
+	}
+}
@@ -1,11 +1,9 @@
 package go_manual_memory
 
 import (
-	"slices"
+	"fmt"
+	"sync"
 	"unsafe"
-
-	esort "github.com/gabe-lee/go_effect_sort"
-	ll "github.com/gabe-lee/go_list_like"
 )
 
 // This allocator simply uses Golang's normal allocation strategy to create new memory,
@@ -13,32 +11,24 @@ import (
 // the user to store the returned memory in any format desired without fear of
 // loss to the garbage collector
 //
-// This is usually used as the 'Parent' allocator for the other allocators in this package
+// Cached references are kept in a map keyed by the address returned from `RawAlloc()`,
+// so allocating, freeing, and resizing are all O(1) regardless of the number of live
+// allocations. Because of this, `RawFree()`, `RawResizeInPlace()`, and `Owns()` must be
+// given the exact pointer returned from `RawAlloc()`, a pointer into the middle of an
+// allocation is treated as unknown
+//
+// This is usually used as the 'Parent' allocator for the other allocators in this package,
+// and is safe for concurrent use by multiple goroutines
 type GoAllocator struct {
-	slices  [][]byte
-	adapter ll.SliceAdapter[[]byte]
+	mu        sync.Mutex
+	slices    map[uintptr][]byte
+	heldBytes uintptr
 }
 
 func NewGoAllocator() *GoAllocator {
-	a := GoAllocator{
-		slices: make([][]byte, 0),
+	return &GoAllocator{
+		slices: make(map[uintptr][]byte),
 	}
-	a.adapter = ll.NewSliceAdapter(&a.slices)
-	return &a
-}
-
-func memSlicesSameAddr(slice ll.SliceLike[[]byte], idx int, val []byte) bool {
-	valAddr := uintptr(unsafe.Pointer(unsafe.SliceData(val)))
-	mem := ll.Get(slice, idx)
-	idxAddr := uintptr(unsafe.Pointer(unsafe.SliceData(mem)))
-	idxEnd := idxAddr + uintptr(len(mem))
-	return idxAddr <= valAddr && valAddr < idxEnd
-}
-func memSliceGreaterAddr(slice ll.SliceLike[[]byte], idx int, val []byte) bool {
-	valPtr := uintptr(unsafe.Pointer(unsafe.SliceData(val)))
-	mem := ll.Get(slice, idx)
-	idxPtr := uintptr(unsafe.Pointer(unsafe.SliceData(mem)))
-	return idxPtr > valPtr
 }
 
 // RawAlloc implements Allocator.
@@ -51,17 +41,28 @@ func (g *GoAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer,
 	delta := alignedAddr - addr
 	ptr = unsafe.Pointer(uintptr(unsafe.Pointer(unsafe.SliceData(mem))) + delta)
 	alloc_len -= delta
-	esort.Sorted_Insert(g.adapter, mem, memSlicesSameAddr, memSliceGreaterAddr, esort.MoveNoSideEffect)
+	g.mu.Lock()
+	g.slices[uintptr(ptr)] = mem
+	g.heldBytes += uintptr(cap(mem))
+	g.mu.Unlock()
 	return
 }
 
 // RawFree implements Allocator.
+//
+// Panics if `ptr` is not a live allocation returned from `RawAlloc()`
 func (g *GoAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
-	mem := unsafe.Slice((*byte)(ptr), len)
-	idx, found := esort.Sorted_Search(g.adapter, mem, memSlicesSameAddr, memSliceGreaterAddr)
-	if found {
-		g.slices = slices.Delete(g.slices, idx, idx+1)
+	if ptr == nil {
+		return
 	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	mem, found := g.slices[uintptr(ptr)]
+	if !found {
+		panic(fmt.Sprintf("fatal: go_manual_memory: GoAllocator.RawFree(): pointer 0x%x was not allocated by this allocator", uintptr(ptr)))
+	}
+	delete(g.slices, uintptr(ptr))
+	g.heldBytes -= uintptr(cap(mem))
 }
 
 // RawResize implements Allocator.
@@ -69,10 +70,10 @@ func (g *GoAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_
 	if new_len <= old_len {
 		return ptr, true
 	}
-	mem := unsafe.Slice((*byte)(ptr), old_len)
-	idx, found := esort.Sorted_Search(g.adapter, mem, memSlicesSameAddr, memSliceGreaterAddr)
+	g.mu.Lock()
+	foundMem, found := g.slices[uintptr(ptr)]
+	g.mu.Unlock()
 	if found {
-		foundMem := g.slices[idx]
 		foundMemAddr := uintptr(unsafe.Pointer(unsafe.SliceData(foundMem)))
 		ptrAddr := uintptr(ptr)
 		delta := ptrAddr - foundMemAddr
@@ -85,4 +86,27 @@ func (g *GoAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_
 	return ptr, false
 }
 
-var _ Allocator = (*GoAllocator)(nil)
+// Owns implements Owner.
+func (g *GoAllocator) Owns(ptr unsafe.Pointer) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, found := g.slices[uintptr(ptr)]
+	return found
+}
+
+// Return the number of allocations currently held by this allocator
+func (g *GoAllocator) LiveCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.slices)
+}
+
+// Return the total number of bytes currently held by this allocator,
+// including any padding added for alignment
+func (g *GoAllocator) HeldBytes() uintptr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.heldBytes
+}
+
+var _ Owner = (*GoAllocator)(nil)
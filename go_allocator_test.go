@@ -0,0 +1,165 @@
+package go_manual_memory
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+	"unsafe"
+
+	esort "github.com/gabe-lee/go_effect_sort"
+	ll "github.com/gabe-lee/go_list_like"
+)
+
+func TestGoAllocator(t *testing.T) {
+	g := NewGoAllocator()
+	live := stressAllocator(t, g, 1, 5000, 1024, PAGE_SIZE)
+	if g.LiveCount() != len(live) {
+		t.Errorf("\ntest case failed: live count\nEXP: %d\nGOT: %d\n", len(live), g.LiveCount())
+	}
+	for _, block := range live {
+		if !g.Owns(block.ptr) {
+			t.Fatalf("\ntest case failed: live block not owned\nBLOCK: 0x%x\n", uintptr(block.ptr))
+		}
+	}
+	freeTestBlocks(g, live)
+	if g.LiveCount() != 0 || g.HeldBytes() != 0 {
+		t.Errorf("\ntest case failed: memory held after freeing everything\nLIVE COUNT: %d\nHELD BYTES: %d\n", g.LiveCount(), g.HeldBytes())
+	}
+}
+
+func TestGoAllocatorResizeInPlace(t *testing.T) {
+	g := NewGoAllocator()
+	ptr, alloc_len := g.RawAlloc(100, 8)
+	if _, ok := g.RawResizeInPlace(ptr, 100, alloc_len); !ok {
+		t.Errorf("\ntest case failed: could not grow within alloc_len\nALLOC LEN: %d\n", alloc_len)
+	}
+	if _, ok := g.RawResizeInPlace(ptr, 100, alloc_len+PAGE_SIZE); ok {
+		t.Errorf("\ntest case failed: grew past the underlying memory\n")
+	}
+	if _, ok := g.RawResizeInPlace(ptr, 100, 10); !ok {
+		t.Errorf("\ntest case failed: could not shrink\n")
+	}
+	var other int
+	if _, ok := g.RawResizeInPlace(unsafe.Pointer(&other), 8, 16); ok {
+		t.Errorf("\ntest case failed: grew memory it does not own\n")
+	}
+	g.RawFree(ptr, 100)
+}
+
+func TestGoAllocatorUnknownPointers(t *testing.T) {
+	g := NewGoAllocator()
+	ptr, alloc_len := g.RawAlloc(64, 8)
+	interior := unsafe.Add(ptr, 8)
+	var other int
+	expectPanic(t, "free of a pointer from another allocator", func() { g.RawFree(unsafe.Pointer(&other), 8) })
+	expectPanic(t, "free of an interior pointer", func() { g.RawFree(interior, 8) })
+	if g.Owns(interior) || g.Owns(unsafe.Pointer(&other)) {
+		t.Errorf("\ntest case failed: unknown pointer owned\n")
+	}
+	if _, ok := g.RawResizeInPlace(interior, 8, 16); ok {
+		t.Errorf("\ntest case failed: grew from an interior pointer\n")
+	}
+	g.RawFree(nil, 0)
+	if g.LiveCount() != 1 {
+		t.Errorf("\ntest case failed: invalid frees changed the live count\nGOT: %d\n", g.LiveCount())
+	}
+	g.RawFree(ptr, alloc_len)
+	expectPanic(t, "double free", func() { g.RawFree(ptr, alloc_len) })
+}
+
+// The registry `GoAllocator` used before it was keyed by address: a slice of every
+// live allocation kept sorted by address, with an O(n) insert and delete. Only used
+// to benchmark against, so it ignores alignment
+type sortedGoRegistry struct {
+	slices  [][]byte
+	adapter ll.SliceAdapter[[]byte]
+}
+
+func newSortedGoRegistry() *sortedGoRegistry {
+	r := sortedGoRegistry{}
+	r.adapter = ll.NewSliceAdapter(&r.slices)
+	return &r
+}
+
+func sortedRegistrySameAddr(slice ll.SliceLike[[]byte], idx int, val []byte) bool {
+	valAddr := uintptr(unsafe.Pointer(unsafe.SliceData(val)))
+	mem := ll.Get(slice, idx)
+	idxAddr := uintptr(unsafe.Pointer(unsafe.SliceData(mem)))
+	return idxAddr <= valAddr && valAddr < idxAddr+uintptr(len(mem))
+}
+
+func sortedRegistryGreaterAddr(slice ll.SliceLike[[]byte], idx int, val []byte) bool {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(ll.Get(slice, idx)))) > uintptr(unsafe.Pointer(unsafe.SliceData(val)))
+}
+
+func (r *sortedGoRegistry) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	mem := make([]byte, len+align)
+	esort.Sorted_Insert(r.adapter, mem, sortedRegistrySameAddr, sortedRegistryGreaterAddr, esort.MoveNoSideEffect)
+	return unsafe.Pointer(unsafe.SliceData(mem)), uintptr(cap(mem))
+}
+
+func (r *sortedGoRegistry) RawFree(ptr unsafe.Pointer, len uintptr) {
+	idx, found := esort.Sorted_Search(r.adapter, unsafe.Slice((*byte)(ptr), len), sortedRegistrySameAddr, sortedRegistryGreaterAddr)
+	if found {
+		r.slices = slices.Delete(r.slices, idx, idx+1)
+	}
+}
+
+func (r *sortedGoRegistry) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	return ptr, new_len <= old_len
+}
+
+// Fill the registry with `count` live allocations without paying the O(n) insert for each one
+func (r *sortedGoRegistry) prefill(count int, len uintptr) []unsafe.Pointer {
+	ptrs := make([]unsafe.Pointer, count)
+	r.slices = make([][]byte, count)
+	for i := range count {
+		r.slices[i] = make([]byte, len)
+		ptrs[i] = unsafe.Pointer(unsafe.SliceData(r.slices[i]))
+	}
+	slices.SortFunc(r.slices, func(a, b []byte) int {
+		return cmp.Compare(uintptr(unsafe.Pointer(unsafe.SliceData(a))), uintptr(unsafe.Pointer(unsafe.SliceData(b))))
+	})
+	r.adapter = ll.NewSliceAdapter(&r.slices)
+	return ptrs
+}
+
+var goAllocatorBenchLiveCounts = []int{10_000, 100_000, 1_000_000}
+
+const goAllocatorBenchLen = 16
+
+// Each iteration frees a random live block and allocates a new one in its place,
+// so the number of live blocks stays fixed
+func benchmarkAllocFree(b *testing.B, alloc Allocator, ptrs []unsafe.Pointer) {
+	r := rand.New(rand.NewSource(1))
+	for b.Loop() {
+		idx := r.Intn(len(ptrs))
+		alloc.RawFree(ptrs[idx], goAllocatorBenchLen)
+		ptrs[idx], _ = alloc.RawAlloc(goAllocatorBenchLen, 8)
+	}
+}
+
+func BenchmarkGoAllocator(b *testing.B) {
+	for _, count := range goAllocatorBenchLiveCounts {
+		b.Run(fmt.Sprintf("live=%d", count), func(b *testing.B) {
+			g := NewGoAllocator()
+			ptrs := make([]unsafe.Pointer, count)
+			for i := range ptrs {
+				ptrs[i], _ = g.RawAlloc(goAllocatorBenchLen, 8)
+			}
+			benchmarkAllocFree(b, g, ptrs)
+		})
+	}
+}
+
+func BenchmarkGoAllocatorSortedRegistry(b *testing.B) {
+	for _, count := range goAllocatorBenchLiveCounts {
+		b.Run(fmt.Sprintf("live=%d", count), func(b *testing.B) {
+			r := newSortedGoRegistry()
+			ptrs := r.prefill(count, goAllocatorBenchLen+8)
+			benchmarkAllocFree(b, r, ptrs)
+		})
+	}
+}
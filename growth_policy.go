@@ -0,0 +1,99 @@
+package go_manual_memory
+
+import "unsafe"
+
+// Decides the new capacity of a `List[T]` that must reallocate to hold at least `minCap`
+// values of `elemSize` bytes each, given its current capacity `oldCap`
+//
+// Any function with this signature can be used as a custom policy. Results smaller than
+// `minCap` are raised to `minCap`. Whatever capacity is chosen, the list then uses all of
+// the extra `alloc_len` the allocator returns, so policies only need to decide the minimum
+//
+// A list uses its own policy if one is set with `List[T].SetGrowthPolicy()`, otherwise
+// the policy of its allocator if it is a `GrowthPolicyAllocator`, otherwise `GrowDoubling()`
+type GrowthPolicy func(oldCap, minCap int, elemSize uintptr) (newCap int)
+
+// Grow to exactly the capacity needed. Uses the least memory,
+// but repeated growth may reallocate every time
+func GrowExact(oldCap, minCap int, elemSize uintptr) (newCap int) {
+	return minCap
+}
+
+// Grow to at least double the old capacity, and at least `MIN_LIST_GROW_CAP`
+func GrowDoubling(oldCap, minCap int, elemSize uintptr) (newCap int) {
+	return max(minCap, oldCap*2, MIN_LIST_GROW_CAP)
+}
+
+// Grow to at least 1.5x the old capacity, and at least `MIN_LIST_GROW_CAP`. Wastes less
+// memory than `GrowDoubling()` at the cost of reallocating more often
+func GrowOneAndHalf(oldCap, minCap int, elemSize uintptr) (newCap int) {
+	return max(minCap, oldCap+oldCap/2, MIN_LIST_GROW_CAP)
+}
+
+// Grow like `GrowDoubling()`, then round the size in bytes up to a multiple of `PAGE_SIZE`,
+// so large lists always fill whole pages from page-based allocators
+func GrowPageRounded(oldCap, minCap int, elemSize uintptr) (newCap int) {
+	newCap = GrowDoubling(oldCap, minCap, elemSize)
+	if elemSize == 0 {
+		return newCap
+	}
+	return int(alignForward(uintptr(newCap)*elemSize, PAGE_SIZE) / elemSize)
+}
+
+// An `Allocator` that provides a default `GrowthPolicy` for every `List[T]` using it
+type GrowthPolicyAllocator interface {
+	Allocator
+	GrowthPolicy() GrowthPolicy
+}
+
+// This allocator wraps any other `Allocator` to set the default `GrowthPolicy` of every
+// `List[T]` that uses it, without changing how memory is allocated
+//
+// The `GrowthAllocator` is safe for concurrent use if the wrapped allocator is
+type GrowthAllocator struct {
+	parentAlloc Allocator
+	policy      GrowthPolicy
+}
+
+// Create a new `GrowthAllocator` that allocates from the parent allocator
+// and provides `policy` to every `List[T]` using it
+func NewGrowthAllocator(parent Allocator, policy GrowthPolicy) *GrowthAllocator {
+	return &GrowthAllocator{
+		parentAlloc: parent,
+		policy:      policy,
+	}
+}
+
+// GrowthPolicy implements GrowthPolicyAllocator.
+func (g *GrowthAllocator) GrowthPolicy() GrowthPolicy {
+	return g.policy
+}
+
+// RawAlloc implements Allocator.
+func (g *GrowthAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	return g.parentAlloc.RawAlloc(len, align)
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (g *GrowthAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	return TryRawAlloc(g.parentAlloc, len, align)
+}
+
+// RawFree implements Allocator.
+func (g *GrowthAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	g.parentAlloc.RawFree(ptr, len)
+}
+
+// RawResizeInPlace implements Allocator.
+func (g *GrowthAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	return g.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// Owns implements Owner.
+func (g *GrowthAllocator) Owns(ptr unsafe.Pointer) bool {
+	return Owns(g.parentAlloc, ptr)
+}
+
+var _ GrowthPolicyAllocator = (*GrowthAllocator)(nil)
+var _ FallibleAllocator = (*GrowthAllocator)(nil)
+var _ Owner = (*GrowthAllocator)(nil)
@@ -0,0 +1,95 @@
+package go_manual_memory
+
+import "testing"
+
+func TestGrowthPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   GrowthPolicy
+		oldCap   int
+		minCap   int
+		elemSize uintptr
+		exp      int
+	}{
+		{"GrowExact", GrowExact, 100, 101, 8, 101},
+		{"GrowDoubling", GrowDoubling, 100, 101, 8, 200},
+		{"GrowDoubling from empty", GrowDoubling, 0, 1, 8, MIN_LIST_GROW_CAP},
+		{"GrowDoubling past double", GrowDoubling, 100, 500, 8, 500},
+		{"GrowOneAndHalf", GrowOneAndHalf, 100, 101, 8, 150},
+		{"GrowOneAndHalf from empty", GrowOneAndHalf, 0, 1, 8, MIN_LIST_GROW_CAP},
+		{"GrowOneAndHalf past 1.5x", GrowOneAndHalf, 100, 500, 8, 500},
+		{"GrowPageRounded", GrowPageRounded, 0, 1, 24, PAGE_SIZE / 24},
+		{"GrowPageRounded exact pages", GrowPageRounded, PAGE_SIZE / 8, PAGE_SIZE/8 + 1, 8, PAGE_SIZE / 4},
+		{"GrowPageRounded zero size", GrowPageRounded, 0, 1, 0, MIN_LIST_GROW_CAP},
+	}
+	for _, test := range tests {
+		if got := test.policy(test.oldCap, test.minCap, test.elemSize); got != test.exp {
+			t.Errorf("\ntest case failed: %s\nEXP: %d\nGOT: %d\n", test.name, test.exp, got)
+		}
+	}
+}
+
+func TestListGrowthPolicy(t *testing.T) {
+	parent := NewGoAllocator()
+	list := CreateList[uint64](0, parent)
+	if list.GrowthPolicy() == nil {
+		t.Fatalf("\ntest case failed: no default growth policy\n")
+	}
+	list.Append(make([]uint64, list.Cap()+1)...)
+	if list.Cap() < MIN_LIST_GROW_CAP {
+		t.Errorf("\ntest case failed: default policy is not GrowDoubling()\nEXP MIN: %d\nGOT: %d\n", MIN_LIST_GROW_CAP, list.Cap())
+	}
+	list.Destroy()
+
+	// A per-list policy overrides the allocator's policy
+	growth := NewGrowthAllocator(NewArenaAllocator(parent, PAGE_SIZE), GrowExact)
+	list = CreateList[uint64](0, growth)
+	list.Append(1, 2, 3)
+	if list.Cap() != 3 {
+		t.Errorf("\ntest case failed: allocator policy\nEXP: 3\nGOT: %d\n", list.Cap())
+	}
+	list.SetGrowthPolicy(func(oldCap, minCap int, elemSize uintptr) int {
+		return oldCap + 10
+	})
+	list.Append(4)
+	if list.Cap() != 13 {
+		t.Errorf("\ntest case failed: per-list policy\nEXP: 13\nGOT: %d\n", list.Cap())
+	}
+	// Results smaller than the needed capacity are raised to it
+	list.SetGrowthPolicy(func(oldCap, minCap int, elemSize uintptr) int {
+		return 0
+	})
+	list.Append(make([]uint64, 20)...)
+	if list.Len() != 24 || list.Cap() != 24 {
+		t.Errorf("\ntest case failed: policy smaller than needed\nLEN: %d\nCAP: %d\n", list.Len(), list.Cap())
+	}
+	list.SetGrowthPolicy(nil)
+	list.Append(5)
+	if list.Cap() != 25 {
+		t.Errorf("\ntest case failed: allocator policy after clearing the list policy\nEXP: 25\nGOT: %d\n", list.Cap())
+	}
+	list.SetGrowthPolicy(GrowDoubling)
+	clone := list.Clone()
+	if clone.GrowthPolicy()(25, 26, 8) != 50 {
+		t.Errorf("\ntest case failed: Clone() did not keep the list policy\n")
+	}
+	clone.Destroy()
+	list.Destroy()
+}
+
+func TestListGrowthUsesAllocLen(t *testing.T) {
+	// Slab slots are rounded up to a power of 2, so a list asking for 3 values gets room for 4
+	slab := NewSlabAllocator(NewGoAllocator())
+	list := CreateList[uint64](0, NewGrowthAllocator(slab, GrowExact))
+	list.Append(1, 2, 3)
+	if list.Cap() != 4 {
+		t.Errorf("\ntest case failed: extra alloc_len not used\nEXP: 4\nGOT: %d\n", list.Cap())
+	}
+	ptr := list.GetPtr(0)
+	list.Append(4)
+	if list.GetPtr(0) != ptr {
+		t.Errorf("\ntest case failed: list reallocated while it still had capacity\n")
+	}
+	list.Destroy()
+	slab.Destroy()
+}
@@ -0,0 +1,145 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// The number of canary bytes placed before and after every allocation by a `GuardAllocator`
+const GUARD_SIZE = 16
+
+// The byte pattern written to the guard regions around every allocation
+const GUARD_BYTE byte = 0xFD
+
+// The byte pattern written over freed memory when poisoning is enabled
+const POISON_BYTE byte = 0xDD
+
+// Stored directly before the front guard region of each allocation
+type guardHeader struct {
+	front uintptr
+	len   uintptr
+}
+
+const guardHeaderSize = unsafe.Sizeof(guardHeader{})
+
+// This allocator wraps any other `Allocator` and surrounds every allocation with
+// `GUARD_SIZE` canary bytes on either side, verifying them when the memory is freed
+// or resized. Any write past the start or end of an allocation (for example through
+// the `[]T` returned by `GoSlice()`) will be caught and cause a panic
+//
+// If `poisonOnFree` is set, freed memory is also filled with `POISON_BYTE` before it
+// is returned to the parent, so use-after-free reads show up as obviously wrong values
+//
+// The returned `alloc_len` is always exactly the requested length, so that the
+// rear guard immediately follows the usable memory
+type GuardAllocator struct {
+	parentAlloc  Allocator
+	poisonOnFree bool
+}
+
+// Create a new `GuardAllocator` that pads all allocations made through the parent allocator
+func NewGuardAllocator(parent Allocator, poisonOnFree bool) *GuardAllocator {
+	return &GuardAllocator{
+		parentAlloc:  parent,
+		poisonOnFree: poisonOnFree,
+	}
+}
+
+func fillBytes(ptr unsafe.Pointer, len uintptr, val byte) {
+	mem := unsafe.Slice((*byte)(ptr), len)
+	for i := range mem {
+		mem[i] = val
+	}
+}
+
+// Return the offset of the first byte that does not match `GUARD_BYTE`, if any
+func checkGuard(ptr unsafe.Pointer) (offset int, ok bool) {
+	mem := unsafe.Slice((*byte)(ptr), GUARD_SIZE)
+	for i, b := range mem {
+		if b != GUARD_BYTE {
+			return i, false
+		}
+	}
+	return 0, true
+}
+
+func (g *GuardAllocator) header(ptr unsafe.Pointer) *guardHeader {
+	return (*guardHeader)(unsafe.Add(ptr, -int(GUARD_SIZE+guardHeaderSize)))
+}
+
+// Verify the guards around an allocation, panicking if either is damaged
+func (g *GuardAllocator) verify(method string, ptr unsafe.Pointer, len uintptr) *guardHeader {
+	if offset, ok := checkGuard(unsafe.Add(ptr, -GUARD_SIZE)); !ok {
+		panic(fmt.Sprintf("fatal: go_manual_memory: GuardAllocator.%s(): buffer underrun detected at pointer 0x%x, front guard damaged %d bytes before start", method, uintptr(ptr), GUARD_SIZE-offset))
+	}
+	head := g.header(ptr)
+	if len != head.len {
+		panic(fmt.Sprintf("fatal: go_manual_memory: GuardAllocator.%s(): pointer 0x%x was passed with len %d, but was allocated with len %d", method, uintptr(ptr), len, head.len))
+	}
+	if offset, ok := checkGuard(unsafe.Add(ptr, head.len)); !ok {
+		panic(fmt.Sprintf("fatal: go_manual_memory: GuardAllocator.%s(): buffer overrun detected at pointer 0x%x, rear guard damaged %d bytes after end (len %d)", method, uintptr(ptr), offset, head.len))
+	}
+	return head
+}
+
+// RawAlloc implements Allocator.
+func (g *GuardAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := g.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (g *GuardAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	align = max(align, unsafe.Alignof(guardHeader{}))
+	front := alignForward(guardHeaderSize+GUARD_SIZE, align)
+	base, _, err := TryRawAlloc(g.parentAlloc, front+len+GUARD_SIZE, align)
+	if err != nil {
+		return nil, 0, err
+	}
+	ptr = unsafe.Add(base, front)
+	fillBytes(unsafe.Add(ptr, -GUARD_SIZE), GUARD_SIZE, GUARD_BYTE)
+	fillBytes(unsafe.Add(ptr, len), GUARD_SIZE, GUARD_BYTE)
+	*g.header(ptr) = guardHeader{
+		front: front,
+		len:   len,
+	}
+	return ptr, len, nil
+}
+
+// RawFree implements Allocator.
+func (g *GuardAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	head := g.verify("RawFree", ptr, len)
+	front := head.front
+	total := front + len + GUARD_SIZE
+	base := unsafe.Add(ptr, -int(front))
+	if g.poisonOnFree {
+		fillBytes(base, total, POISON_BYTE)
+	}
+	g.parentAlloc.RawFree(base, total)
+}
+
+// RawResizeInPlace implements Allocator.
+func (g *GuardAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	head := g.verify("RawResizeInPlace", ptr, old_len)
+	front := head.front
+	base := unsafe.Add(ptr, -int(front))
+	// Nothing is touched until the parent succeeds, so a failed resize leaves the
+	// allocation exactly as it was
+	_, success = g.parentAlloc.RawResizeInPlace(base, front+old_len+GUARD_SIZE, front+new_len+GUARD_SIZE)
+	if !success {
+		return ptr, false
+	}
+	// When shrinking, memory past the new rear guard belongs to the parent again and
+	// may already hold its bookkeeping, so it is not poisoned
+	fillBytes(unsafe.Add(ptr, new_len), GUARD_SIZE, GUARD_BYTE)
+	head.len = new_len
+	return ptr, true
+}
+
+var _ FallibleAllocator = (*GuardAllocator)(nil)
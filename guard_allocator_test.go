@@ -0,0 +1,107 @@
+package go_manual_memory
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestGuardAllocator(t *testing.T) {
+	parent := NewGoAllocator()
+	g := NewGuardAllocator(parent, true)
+	live := stressAllocator(t, g, 1, 5000, 512, 256)
+	freeTestBlocks(g, live)
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestGuardAllocatorDetection(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(mem []byte)
+	}{
+		{"overrun", func(mem []byte) { unsafe.Slice(unsafe.SliceData(mem), len(mem)+1)[len(mem)] = 0 }},
+		{"last rear guard byte", func(mem []byte) { unsafe.Slice(unsafe.SliceData(mem), len(mem)+GUARD_SIZE)[len(mem)+GUARD_SIZE-1] = 0 }},
+		{"underrun", func(mem []byte) { *(*byte)(unsafe.Add(unsafe.Pointer(unsafe.SliceData(mem)), -1)) = 0 }},
+		{"first front guard byte", func(mem []byte) { *(*byte)(unsafe.Add(unsafe.Pointer(unsafe.SliceData(mem)), -GUARD_SIZE)) = 0 }},
+	}
+	for _, test := range tests {
+		g := NewGuardAllocator(NewGoAllocator(), false)
+		mem := Alloc[byte](g, 40)
+		test.damage(mem)
+		expectPanic(t, test.name+" on free", func() { Free(g, mem) })
+		mem = Alloc[byte](g, 40)
+		test.damage(mem)
+		expectPanic(t, test.name+" on resize", func() { g.RawResizeInPlace(unsafe.Pointer(unsafe.SliceData(mem)), 40, 20) })
+	}
+	g := NewGuardAllocator(NewGoAllocator(), false)
+	ptr, alloc_len := g.RawAlloc(40, 8)
+	if alloc_len != 40 {
+		t.Errorf("\ntest case failed: alloc_len\nEXP: 40\nGOT: %d\n", alloc_len)
+	}
+	expectPanic(t, "free with the wrong length", func() { g.RawFree(ptr, 48) })
+	g.RawFree(ptr, 40)
+}
+
+// An allocator that refuses every resize, even a shrink
+type noResizeAllocator struct {
+	parentAlloc Allocator
+}
+
+func (n noResizeAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	return n.parentAlloc.RawAlloc(len, align)
+}
+
+func (n noResizeAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	n.parentAlloc.RawFree(ptr, len)
+}
+
+func (n noResizeAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	return ptr, false
+}
+
+func TestGuardAllocatorRefusedResize(t *testing.T) {
+	g := NewGuardAllocator(noResizeAllocator{NewGoAllocator()}, true)
+	mem := Alloc[byte](g, 64)
+	for i := range mem {
+		mem[i] = byte(i)
+	}
+	ptr := unsafe.Pointer(unsafe.SliceData(mem))
+	for _, new_len := range []uintptr{32, 128} {
+		if _, ok := g.RawResizeInPlace(ptr, 64, new_len); ok {
+			t.Fatalf("\ntest case failed: resize to %d succeeded through a refusing parent\n", new_len)
+		}
+		for i, b := range mem {
+			if b != byte(i) {
+				t.Fatalf("\ntest case failed: data changed by a refused resize to %d\nOFFSET: %d\nGOT: 0x%x\n", new_len, i, b)
+			}
+		}
+	}
+	// Freeing verifies the original guards and length are still in place
+	Free(g, mem)
+}
+
+func TestGuardAllocatorPoison(t *testing.T) {
+	arena := NewArenaAllocator(NewGoAllocator(), PAGE_SIZE)
+	g := NewGuardAllocator(arena, true)
+	mem := Alloc[byte](g, 64)
+	for i := range mem {
+		mem[i] = 1
+	}
+	if _, ok := g.RawResizeInPlace(unsafe.Pointer(unsafe.SliceData(mem)), 64, 32); !ok {
+		t.Fatalf("\ntest case failed: shrink of last arena allocation\n")
+	}
+	// The rear guard now starts at the new end
+	if _, ok := checkGuard(unsafe.Add(unsafe.Pointer(unsafe.SliceData(mem)), 32)); !ok {
+		t.Fatalf("\ntest case failed: rear guard not moved to the new end\n")
+	}
+	mem = mem[:32:32]
+	Free(g, mem)
+	// The arena does not reuse the memory, so it can still be inspected after the free
+	for i, b := range mem {
+		if b != POISON_BYTE {
+			t.Fatalf("\ntest case failed: freed memory not poisoned\nOFFSET: %d\nGOT: 0x%x\n", i, b)
+		}
+	}
+	arena.Destroy()
+}
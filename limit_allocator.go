@@ -0,0 +1,158 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// This allocator wraps any other `Allocator` and enforces a hard limit on the number of
+// bytes that can be in use at once. Allocations that would exceed the limit fail with
+// `ErrOutOfMemory` through `TryRawAlloc()` (and therefore `TryAlloc()`, `TryCreate()`,
+// `List[T].TryOffsetLen()`, etc.), or panic through `RawAlloc()`
+//
+// An optional soft limit can also be set, with a callback that is run each time
+// the bytes in use rise above it
+//
+// The returned `alloc_len` is always exactly the requested length, so that the
+// number of bytes in use stays exact when memory is freed
+//
+// The limit is enforced correctly under concurrent use, but the `LimitAllocator`
+// itself is only safe for concurrent use if the wrapped allocator is
+type LimitAllocator struct {
+	parentAlloc Allocator
+	hardLimit   atomic.Uint64
+	bytesInUse  atomic.Uint64
+	softMu      sync.Mutex
+	softLimit   uint64
+	onSoftLimit func(bytesInUse uint64)
+}
+
+// Create a new `LimitAllocator` that allows at most `hardLimit` bytes to be
+// allocated from the parent allocator at once
+func NewLimitAllocator(parent Allocator, hardLimit uint64) *LimitAllocator {
+	l := LimitAllocator{
+		parentAlloc: parent,
+	}
+	l.hardLimit.Store(hardLimit)
+	return &l
+}
+
+// Return the number of bytes currently in use
+func (l *LimitAllocator) BytesInUse() uint64 {
+	return l.bytesInUse.Load()
+}
+
+// Return the current hard limit
+func (l *LimitAllocator) HardLimit() uint64 {
+	return l.hardLimit.Load()
+}
+
+// Change the hard limit
+//
+// Lowering the limit below the bytes currently in use does not free anything,
+// but all further allocations will fail until enough memory is freed
+func (l *LimitAllocator) SetHardLimit(hardLimit uint64) {
+	l.hardLimit.Store(hardLimit)
+}
+
+// Set a soft limit, and a callback that is run with the new bytes in use each time
+// an allocation causes the bytes in use to rise above it
+//
+// The callback is run on the allocating goroutine and MUST NOT allocate from this allocator.
+// Pass a nil callback to remove the soft limit
+func (l *LimitAllocator) SetSoftLimit(softLimit uint64, onSoftLimit func(bytesInUse uint64)) {
+	l.softMu.Lock()
+	defer l.softMu.Unlock()
+	l.softLimit = softLimit
+	l.onSoftLimit = onSoftLimit
+}
+
+// Attempt to reserve `delta` more bytes without going over the hard limit
+func (l *LimitAllocator) reserve(delta uint64) bool {
+	for {
+		inUse := l.bytesInUse.Load()
+		if inUse+delta > l.hardLimit.Load() || inUse+delta < inUse {
+			return false
+		}
+		if l.bytesInUse.CompareAndSwap(inUse, inUse+delta) {
+			l.checkSoftLimit(inUse, inUse+delta)
+			return true
+		}
+	}
+}
+
+func (l *LimitAllocator) release(delta uint64) {
+	l.bytesInUse.Add(-delta)
+}
+
+func (l *LimitAllocator) checkSoftLimit(oldInUse, newInUse uint64) {
+	l.softMu.Lock()
+	softLimit, onSoftLimit := l.softLimit, l.onSoftLimit
+	l.softMu.Unlock()
+	if onSoftLimit != nil && oldInUse <= softLimit && newInUse > softLimit {
+		onSoftLimit(newInUse)
+	}
+}
+
+// RawAlloc implements Allocator.
+func (l *LimitAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := l.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (l *LimitAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if !l.reserve(uint64(len)) {
+		return nil, 0, fmt.Errorf("%w: LimitAllocator.TryRawAlloc(): requested len %d would exceed hard limit %d (%d bytes in use)", ErrOutOfMemory, len, l.HardLimit(), l.BytesInUse())
+	}
+	ptr, _, err = TryRawAlloc(l.parentAlloc, len, align)
+	if err != nil {
+		l.release(uint64(len))
+		return nil, 0, err
+	}
+	return ptr, len, nil
+}
+
+// RawFree implements Allocator.
+func (l *LimitAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	l.parentAlloc.RawFree(ptr, len)
+	l.release(uint64(len))
+}
+
+// RawResizeInPlace implements Allocator.
+//
+// Growing fails if it would exceed the hard limit
+func (l *LimitAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if new_len <= old_len {
+		newPtr, success = l.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+		if success {
+			l.release(uint64(old_len - new_len))
+		}
+		return
+	}
+	delta := uint64(new_len - old_len)
+	if !l.reserve(delta) {
+		return ptr, false
+	}
+	newPtr, success = l.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	if !success {
+		l.release(delta)
+	}
+	return
+}
+
+// Owns implements Owner.
+func (l *LimitAllocator) Owns(ptr unsafe.Pointer) bool {
+	return Owns(l.parentAlloc, ptr)
+}
+
+var _ FallibleAllocator = (*LimitAllocator)(nil)
+var _ Owner = (*LimitAllocator)(nil)
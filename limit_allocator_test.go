@@ -0,0 +1,119 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestLimitAllocator(t *testing.T) {
+	l := NewLimitAllocator(NewArenaAllocator(NewGoAllocator(), PAGE_SIZE), 100)
+	a, a_len := l.RawAlloc(60, 8)
+	if a_len != 60 || l.BytesInUse() != 60 {
+		t.Errorf("\ntest case failed: alloc_len and bytes in use\nEXP: 60, 60\nGOT: %d, %d\n", a_len, l.BytesInUse())
+	}
+	if _, _, err := l.TryRawAlloc(41, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: allocation over the hard limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	expectPanic(t, "RawAlloc() over the hard limit", func() { l.RawAlloc(41, 8) })
+	if l.BytesInUse() != 60 {
+		t.Errorf("\ntest case failed: failed allocations changed bytes in use\nEXP: 60\nGOT: %d\n", l.BytesInUse())
+	}
+	b, _ := l.RawAlloc(40, 1)
+	if l.BytesInUse() != 100 {
+		t.Errorf("\ntest case failed: allocation up to the exact hard limit\nEXP: 100\nGOT: %d\n", l.BytesInUse())
+	}
+	if _, ok := l.RawResizeInPlace(b, 40, 41); ok || l.BytesInUse() != 100 {
+		t.Errorf("\ntest case failed: resize over the hard limit\nOK: %v\nBYTES: %d\n", ok, l.BytesInUse())
+	}
+	if _, ok := l.RawResizeInPlace(b, 40, 10); !ok || l.BytesInUse() != 70 {
+		t.Errorf("\ntest case failed: shrink of last arena allocation\nOK: %v\nBYTES: %d\n", ok, l.BytesInUse())
+	}
+	if _, ok := l.RawResizeInPlace(a, 60, 70); ok || l.BytesInUse() != 70 {
+		t.Errorf("\ntest case failed: failed parent resize kept its reservation\nOK: %v\nBYTES: %d\n", ok, l.BytesInUse())
+	}
+	l.SetHardLimit(50)
+	if l.HardLimit() != 50 {
+		t.Errorf("\ntest case failed: hard limit\nEXP: 50\nGOT: %d\n", l.HardLimit())
+	}
+	if _, _, err := l.TryRawAlloc(1, 1); err == nil {
+		t.Errorf("\ntest case failed: allocation while over a lowered hard limit\n")
+	}
+	l.RawFree(a, 60)
+	l.RawFree(b, 10)
+	if l.BytesInUse() != 0 {
+		t.Errorf("\ntest case failed: bytes in use after freeing everything\nGOT: %d\n", l.BytesInUse())
+	}
+	if _, _, err := l.TryRawAlloc(^uintptr(0), 1); err == nil {
+		t.Errorf("\ntest case failed: overflowing allocation\n")
+	}
+}
+
+func TestLimitAllocatorParentFailure(t *testing.T) {
+	l := NewLimitAllocator(failingAllocator{NewGoAllocator(), 64}, 1000)
+	if _, _, err := l.TryRawAlloc(65, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: parent failure\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if l.BytesInUse() != 0 {
+		t.Errorf("\ntest case failed: reservation kept after parent failure\nGOT: %d\n", l.BytesInUse())
+	}
+}
+
+func TestLimitAllocatorSoftLimit(t *testing.T) {
+	l := NewLimitAllocator(NewGoAllocator(), 1000)
+	calls := make([]uint64, 0)
+	l.SetSoftLimit(100, func(bytesInUse uint64) {
+		calls = append(calls, bytesInUse)
+	})
+	a := Alloc[byte](l, 100)
+	b := Alloc[byte](l, 50)
+	c := Alloc[byte](l, 50)
+	Free(l, b)
+	Free(l, c)
+	b = Alloc[byte](l, 1)
+	if len(calls) != 2 || calls[0] != 150 || calls[1] != 101 {
+		t.Errorf("\ntest case failed: soft limit callbacks\nEXP: [150 101]\nGOT: %v\n", calls)
+	}
+	Free(l, b)
+	l.SetSoftLimit(0, nil)
+	Free(l, Alloc[byte](l, 200))
+	if len(calls) != 2 {
+		t.Errorf("\ntest case failed: callback run after soft limit removed\nGOT: %v\n", calls)
+	}
+	Free(l, a)
+}
+
+func TestLimitAllocatorConcurrent(t *testing.T) {
+	const limit = 64 * 1024
+	l := NewLimitAllocator(NewGoAllocator(), limit)
+	stressAllocatorConcurrent(t, NewLimitAllocator(NewGoAllocator(), 1<<30), 8, 512, 64)
+	var wg sync.WaitGroup
+	held := make([][][]byte, 8)
+	for w := range held {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mem, err := TryAlloc[byte](l, 100)
+				if err != nil {
+					return
+				}
+				held[w] = append(held[w], mem)
+			}
+		}()
+	}
+	wg.Wait()
+	total := 0
+	for _, blocks := range held {
+		total += len(blocks) * 100
+		for _, mem := range blocks {
+			Free(l, mem)
+		}
+	}
+	if total > limit || total <= limit-100*len(held) {
+		t.Errorf("\ntest case failed: bytes allocated concurrently\nEXP: %d to %d\nGOT: %d\n", limit-100*len(held)+1, limit, total)
+	}
+	if l.BytesInUse() != 0 {
+		t.Errorf("\ntest case failed: bytes in use after freeing everything\nGOT: %d\n", l.BytesInUse())
+	}
+}
@@ -1,16 +1,21 @@
 package go_manual_memory
 
 import (
+	"math"
 	"unsafe"
 
 	ll "github.com/gabe-lee/go_list_like"
 )
 
+// The smallest capacity a `List[T]` grows to when it must reallocate
+const MIN_LIST_GROW_CAP = 8
+
 type List[T any] struct {
-	ptr   *T
-	len   uint32
-	cap   uint32
-	alloc Allocator
+	ptr    *T
+	len    uint32
+	cap    uint32
+	alloc  Allocator
+	growth GrowthPolicy
 }
 
 // Copies the data from provided Golang slice into a new `List[T]`
@@ -22,10 +27,19 @@ func CreateListCopyFrom[T any](data []T, alloc Allocator) List[T] {
 
 // Cretes a new `List[T]` with specified length, using provided `Allocator`
 func CreateList[T any](listLen int, alloc Allocator) List[T] {
+	checkLen("CreateList()", listLen)
 	slice := CreateSlice[T](listLen, alloc)
 	return slice.ToList(alloc)
 }
 
+// Like `CreateList()`, but returns an error instead of panicking if the
+// memory cannot be allocated
+func TryCreateList[T any](listLen int, alloc Allocator) (List[T], error) {
+	checkLen("TryCreateList()", listLen)
+	slice, err := TryCreateSlice[T](listLen, alloc)
+	return slice.ToList(alloc), err
+}
+
 // Convert this `List[T]` into a `Slice[T]`, handing off ownership
 // of the data to the new slice
 func (l *List[T]) ToSlice() Slice[T] {
@@ -54,7 +68,9 @@ func (l *List[T]) AsSlice() Slice[T] {
 func (l *List[T]) Clone() List[T] {
 	slice := l.AsSlice()
 	newSlice := slice.Clone(l.alloc)
-	return newSlice.ToList(l.alloc)
+	newList := newSlice.ToList(l.alloc)
+	newList.growth = l.growth
+	return newList
 }
 
 // Destroy this `List[T]`, returning the memory to the cached `Allocator`
@@ -87,19 +103,225 @@ func (l *List[T]) GetPtr(idx int) *T {
 }
 
 // Grow or shrink the list length, resizing/reallocating if neccessary
+//
+// When the list must reallocate, its new capacity is chosen by its `GrowthPolicy`
 func (l *List[T]) OffsetLen(delta int) {
+	if err := l.TryOffsetLen(delta); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `OffsetLen()`, but returns an error instead of panicking if the list
+// needs to grow and the memory cannot be allocated, in which case the list is unchanged
+func (l *List[T]) TryOffsetLen(delta int) error {
 	if delta < 0 {
+		checkShrink("List[T].OffsetLen()", -delta, l.Len())
 		l.len -= uint32(-delta)
+		return nil
+	}
+	if err := l.tryGrowCap(l.Len() + delta); err != nil {
+		return err
+	}
+	l.len += uint32(delta)
+	return nil
+}
+
+// Set the `GrowthPolicy` used whenever this list must reallocate to grow,
+// overriding the policy of its allocator. Pass nil to use the default again
+func (l *List[T]) SetGrowthPolicy(policy GrowthPolicy) {
+	l.growth = policy
+}
+
+// Return the `GrowthPolicy` this list uses to grow
+func (l *List[T]) GrowthPolicy() GrowthPolicy {
+	if l.growth != nil {
+		return l.growth
+	}
+	if provider, ok := l.alloc.(GrowthPolicyAllocator); ok {
+		return provider.GrowthPolicy()
+	}
+	return GrowDoubling
+}
+
+// Ensure the list has room for at least `minCap` values, choosing the new
+// capacity with the list's `GrowthPolicy`
+func (l *List[T]) tryGrowCap(minCap int) error {
+	if minCap <= l.Cap() {
+		return nil
+	}
+	checkLen("List[T] growth", minCap)
+	newCap := int(min(uint64(max(minCap, l.GrowthPolicy()(l.Cap(), minCap, unsafe.Sizeof(*new(T))))), math.MaxUint32))
+	newMem, err := TryResizeCanMove(l.alloc, l.GoSlice(), newCap)
+	if err != nil {
+		return err
+	}
+	l.ptr = unsafe.SliceData(newMem)
+	l.cap = clampCap(cap(newMem))
+	return nil
+}
+
+// Ensure there is room to add at least `additional` more values without reallocating
+func (l *List[T]) Reserve(additional int) {
+	if err := l.TryReserve(additional); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `Reserve()`, but returns an error instead of panicking if the
+// memory cannot be allocated, in which case the list is unchanged
+func (l *List[T]) TryReserve(additional int) error {
+	return l.tryGrowCap(l.Len() + additional)
+}
+
+// Add values to the end of the list, growing it if neccessary
+//
+// Analogous to `slice = append(slice, vals...)`
+func (l *List[T]) Append(vals ...T) {
+	if err := l.TryAppend(vals...); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `Append()`, but returns an error instead of panicking if the list
+// needs to grow and the memory cannot be allocated, in which case the list is unchanged
+//
+// `vals` MUST NOT refer to the memory of this list, as it may be freed when the list grows
+func (l *List[T]) TryAppend(vals ...T) error {
+	if err := l.tryGrowCap(l.Len() + len(vals)); err != nil {
+		return err
+	}
+	start := l.Len()
+	l.len += uint32(len(vals))
+	copy(l.GoSlice()[start:], vals)
+	return nil
+}
+
+// Add all values in another slice-like container (like a `Slice[T]`, `SubSlice[T]`,
+// or `List[T]`) to the end of the list, growing it if neccessary
+func (l *List[T]) AppendSlice(vals ll.SliceLike[T]) {
+	if err := l.TryAppendSlice(vals); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `AppendSlice()`, but returns an error instead of panicking if the list
+// needs to grow and the memory cannot be allocated, in which case the list is unchanged
+//
+// `vals` MUST NOT refer to the memory of this list, as it may be freed when the list grows
+func (l *List[T]) TryAppendSlice(vals ll.SliceLike[T]) error {
+	if err := l.tryGrowCap(l.Len() + vals.Len()); err != nil {
+		return err
+	}
+	start := l.Len()
+	l.len += uint32(vals.Len())
+	data := l.GoSlice()
+	for i := range vals.Len() {
+		data[start+i] = *vals.GetPtr(i)
+	}
+	return nil
+}
+
+// Insert values at index `idx`, moving all values at and after `idx` to make room,
+// growing the list if neccessary
+//
+// Analogous to `slices.Insert(slice, idx, vals...)`
+func (l *List[T]) Insert(idx int, vals ...T) {
+	if err := l.TryInsert(idx, vals...); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `Insert()`, but returns an error instead of panicking if the list
+// needs to grow and the memory cannot be allocated, in which case the list is unchanged
+//
+// `vals` MUST NOT refer to the memory of this list, as it may be freed when the list grows
+func (l *List[T]) TryInsert(idx int, vals ...T) error {
+	checkIndex("List[T].Insert()", idx, l.Len()+1)
+	// Slicing before the list changes lets the Golang runtime catch an invalid index
+	// when the checks are compiled out
+	_ = l.GoSlice()[idx:]
+	if err := l.tryGrowCap(l.Len() + len(vals)); err != nil {
+		return err
+	}
+	l.len += uint32(len(vals))
+	data := l.GoSlice()
+	copy(data[idx+len(vals):], data[idx:])
+	copy(data[idx:], vals)
+	return nil
+}
+
+// Remove and return the value at index `idx`, moving all values after it down to fill the gap
+//
+// This is O(n), use `SwapRemove()` when the order of values does not matter
+func (l *List[T]) Remove(idx int) T {
+	data := l.GoSlice()
+	val := data[idx]
+	copy(data[idx:], data[idx+1:])
+	l.len -= 1
+	return val
+}
+
+// Remove and return the value at index `idx`, replacing it with the last value in the list
+//
+// This is O(1), but does not preserve the order of values
+func (l *List[T]) SwapRemove(idx int) T {
+	data := l.GoSlice()
+	val := data[idx]
+	data[idx] = data[len(data)-1]
+	l.len -= 1
+	return val
+}
+
+// Remove the values from index `start` up to (but not including) index `end`,
+// moving all values after them down to fill the gap
+//
+// Analogous to `slices.Delete(slice, start, end)`
+func (l *List[T]) RemoveRange(start, end int) {
+	checkSubSlice("List[T].RemoveRange()", start, end, l.Len())
+	data := l.GoSlice()
+	// Slicing up to the length lets the Golang runtime catch invalid bounds
+	// when the checks are compiled out
+	count := len(data[start:end:len(data)])
+	copy(data[start:], data[end:])
+	l.len -= uint32(count)
+}
+
+// Shorten the list to `newLen` values, keeping the capacity. Does nothing if
+// `newLen` is greater than or equal to the current length
+func (l *List[T]) Truncate(newLen int) {
+	checkLen("List[T].Truncate()", newLen)
+	if newLen < l.Len() {
+		l.len = uint32(newLen)
+	}
+}
+
+// Remove all values from the list, keeping the capacity
+func (l *List[T]) Clear() {
+	l.len = 0
+}
+
+// Shrink the capacity of the list as close to its length as the allocator allows,
+// returning the memory to the cached `Allocator`. If the list is empty its memory
+// is freed entirely
+//
+// If the allocator cannot shrink the memory in place and new memory cannot be
+// allocated, the list is left unchanged
+func (l *List[T]) ShrinkToFit() {
+	if l.len == l.cap {
+		return
+	}
+	if l.len == 0 {
+		Free(l.alloc, l.GoSlice())
+		l.ptr = nil
+		l.cap = 0
 		return
 	}
-	space := l.cap - l.len
-	if delta <= int(space) {
-		l.len += uint32(delta)
+	newMem, err := TryResizeCanMove(l.alloc, l.GoSlice(), l.Len())
+	if err != nil {
 		return
 	}
-	newSlice := ResizeCanMove(l.alloc, l.GoSlice(), l.Len()+delta)
-	newSSLice := sliceFromSlice(newSlice)
-	*l = newSSLice.ToList(l.alloc)
+	l.ptr = unsafe.SliceData(newMem)
+	l.cap = clampCap(cap(newMem))
 }
 
 var _ ll.ListLike[byte] = (*List[byte])(nil)
@@ -0,0 +1,71 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"math"
+	"math/bits"
+	"testing"
+	"unsafe"
+)
+
+// An allocator that reports `reportLen` as the `alloc_len` of every allocation, no matter
+// how much memory it really provides, and refuses to resize
+//
+// The memory comes from a `PageAllocator`, since the runtime rejects slices that
+// run past the end of a Golang heap object when checking pointers
+type overReportingAllocator struct {
+	parentAlloc *PageAllocator
+	reportLen   uintptr
+	lens        map[uintptr]uintptr
+}
+
+func (o *overReportingAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len = o.parentAlloc.RawAlloc(len, align)
+	if ptr == nil {
+		return nil, 0
+	}
+	o.lens[uintptr(ptr)] = alloc_len
+	return ptr, o.reportLen
+}
+
+func (o *overReportingAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	o.parentAlloc.RawFree(ptr, o.lens[uintptr(ptr)])
+	delete(o.lens, uintptr(ptr))
+}
+
+func (o *overReportingAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	return ptr, false
+}
+
+func TestListCapClamp(t *testing.T) {
+	if bits.UintSize != 64 {
+		t.Skip("an alloc_len past the max capacity does not fit in uintptr on 32-bit platforms")
+	}
+	// Converted at runtime, since the constant would overflow `uintptr` on 32-bit platforms
+	maxCap := uint64(math.MaxUint32)
+	alloc := &overReportingAllocator{
+		parentAlloc: NewPageAllocator(),
+		reportLen:   uintptr(maxCap * 2),
+		lens:        make(map[uintptr]uintptr),
+	}
+	list := CreateList[byte](0, alloc)
+	list.Append(1, 2, 3)
+	if uint64(list.Cap()) != maxCap {
+		t.Errorf("\ntest case failed: capacity after growth\nEXP: %d\nGOT: %d\n", maxCap, list.Cap())
+	}
+	list.ShrinkToFit()
+	if uint64(list.Cap()) != maxCap {
+		t.Errorf("\ntest case failed: capacity after ShrinkToFit()\nEXP: %d\nGOT: %d\n", maxCap, list.Cap())
+	}
+	if got := list.GoSlice(); string(got) != "\x01\x02\x03" {
+		t.Errorf("\ntest case failed: values after ShrinkToFit()\nEXP: [1 2 3]\nGOT: %v\n", got)
+	}
+	list.Destroy()
+	if len(alloc.lens) != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", len(alloc.lens))
+	}
+}
@@ -0,0 +1,142 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestListTryGrowth(t *testing.T) {
+	parent := NewGoAllocator()
+	arena := NewArenaAllocator(parent, PAGE_SIZE)
+	alloc := failingAllocator{arena, 64}
+	if _, err := TryCreateList[uint64](9, alloc); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: TryCreateList() over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	list, err := TryCreateList[uint64](0, alloc)
+	if err != nil {
+		t.Fatalf("\ntest case failed: TryCreateList()\nGOT ERR: %v\n", err)
+	}
+	list.Append(1, 2, 3)
+	cap := list.Cap()
+	for _, try := range []func() error{
+		func() error { return list.TryOffsetLen(9) },
+		func() error { return list.TryAppend(make([]uint64, 9)...) },
+		func() error { return list.TryReserve(9) },
+		func() error { return list.TryInsert(0, make([]uint64, 9)...) },
+	} {
+		if err := try(); !errors.Is(err, ErrOutOfMemory) {
+			t.Errorf("\ntest case failed: growth over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+		}
+		if list.Len() != 3 || list.Cap() != cap || *list.GetPtr(2) != 3 {
+			t.Errorf("\ntest case failed: list changed by failed growth\nLEN: %d\nCAP: %d\n", list.Len(), list.Cap())
+		}
+	}
+	expectPanic(t, "OffsetLen() over the limit", func() { list.OffsetLen(9) })
+	if err := list.TryOffsetLen(-2); err != nil || list.Len() != 1 {
+		t.Errorf("\ntest case failed: TryOffsetLen() shrink\nLEN: %d\nGOT ERR: %v\n", list.Len(), err)
+	}
+	list.Destroy()
+	arena.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func checkListValues(t *testing.T, what string, list *List[int], exp ...int) {
+	t.Helper()
+	got := make([]int, 0, list.Len())
+	for i := range list.Len() {
+		got = append(got, *list.GetPtr(i))
+	}
+	if !slices.Equal(got, exp) {
+		t.Errorf("\ntest case failed: %s\nEXP: %v\nGOT: %v\n", what, exp, got)
+	}
+}
+
+func TestList(t *testing.T) {
+	parent := NewGoAllocator()
+	list := CreateList[int](0, parent)
+	for i := range 100 {
+		list.Append(i)
+	}
+	if list.Len() != 100 || list.Cap() < 100 {
+		t.Fatalf("\ntest case failed: Append() growth\nLEN: %d\nCAP: %d\n", list.Len(), list.Cap())
+	}
+	list.Truncate(5)
+	checkListValues(t, "Truncate()", &list, 0, 1, 2, 3, 4)
+	list.Truncate(10)
+	checkListValues(t, "Truncate() past the length", &list, 0, 1, 2, 3, 4)
+	list.Insert(2, 10, 11)
+	checkListValues(t, "Insert()", &list, 0, 1, 10, 11, 2, 3, 4)
+	list.Insert(list.Len(), 12)
+	checkListValues(t, "Insert() at the end", &list, 0, 1, 10, 11, 2, 3, 4, 12)
+	if val := list.Remove(2); val != 10 {
+		t.Errorf("\ntest case failed: Remove() value\nEXP: 10\nGOT: %d\n", val)
+	}
+	checkListValues(t, "Remove()", &list, 0, 1, 11, 2, 3, 4, 12)
+	if val := list.SwapRemove(0); val != 0 {
+		t.Errorf("\ntest case failed: SwapRemove() value\nEXP: 0\nGOT: %d\n", val)
+	}
+	checkListValues(t, "SwapRemove()", &list, 12, 1, 11, 2, 3, 4)
+	list.RemoveRange(1, 3)
+	checkListValues(t, "RemoveRange()", &list, 12, 2, 3, 4)
+	list.RemoveRange(2, 2)
+	checkListValues(t, "RemoveRange() of nothing", &list, 12, 2, 3, 4)
+	list.RemoveRange(2, 4)
+	checkListValues(t, "RemoveRange() to the end", &list, 12, 2)
+	clone := list.Clone()
+	clone.Append(5)
+	checkListValues(t, "Clone()", &clone, 12, 2, 5)
+	checkListValues(t, "original after Clone()", &list, 12, 2)
+	// Enough values that the list must grow to fit them
+	extra := CreateListCopyFrom(make([]int, list.Cap()), parent)
+	*extra.GetPtr(0) = 7
+	clone.AppendSlice(extra.WholeSlice())
+	if clone.Len() != 3+extra.Len() || *clone.GetPtr(3) != 7 || *clone.GetPtr(2) != 5 {
+		t.Errorf("\ntest case failed: AppendSlice()\nLEN: %d\n", clone.Len())
+	}
+	extra.Destroy()
+	clone.Destroy()
+	list.Reserve(50)
+	if list.Cap() < 52 || list.Len() != 2 {
+		t.Errorf("\ntest case failed: Reserve()\nLEN: %d\nCAP: %d\n", list.Len(), list.Cap())
+	}
+	list.ShrinkToFit()
+	if list.Cap() < 2 || list.Cap() >= 52 {
+		t.Errorf("\ntest case failed: ShrinkToFit()\nCAP: %d\n", list.Cap())
+	}
+	checkListValues(t, "ShrinkToFit()", &list, 12, 2)
+	list.Clear()
+	list.ShrinkToFit()
+	if list.Len() != 0 || list.Cap() != 0 {
+		t.Errorf("\ntest case failed: ShrinkToFit() of an empty list\nLEN: %d\nCAP: %d\n", list.Len(), list.Cap())
+	}
+	list.Append(1)
+	list.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestListBounds(t *testing.T) {
+	list := CreateListCopyFrom([]int{0, 1, 2, 3, 4}, NewGoAllocator())
+	defer list.Destroy()
+	for _, bounds := range [][2]int{{3, 1}, {-1, 2}, {0, 6}, {6, 6}} {
+		expectPanic(t, fmt.Sprintf("RemoveRange(%d, %d)", bounds[0], bounds[1]), func() {
+			list.RemoveRange(bounds[0], bounds[1])
+		})
+	}
+	checkListValues(t, "list after invalid RemoveRange()", &list, 0, 1, 2, 3, 4)
+	expectPanic(t, "Insert() past the end", func() { list.Insert(6, 1) })
+	expectPanic(t, "Insert() at a negative index", func() { list.Insert(-1, 1) })
+	expectPanic(t, "Remove() past the end", func() { list.Remove(5) })
+	expectPanic(t, "SwapRemove() past the end", func() { list.SwapRemove(5) })
+	if SAFETY_CHECKS {
+		expectPanic(t, "Truncate() to a negative length", func() { list.Truncate(-1) })
+	}
+	checkListValues(t, "list after invalid calls", &list, 0, 1, 2, 3, 4)
+	list.RemoveRange(0, 5)
+	checkListValues(t, "RemoveRange() of everything", &list)
+}
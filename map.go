@@ -0,0 +1,289 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"hash/maphash"
+	"iter"
+	"math"
+	"math/bits"
+	"unsafe"
+)
+
+// The smallest number of slots a `Map[K, V]` allocates
+const MIN_MAP_CAP = 8
+
+// The largest capacity a `Map[K, V]` can have, since its capacity is always a power of 2
+const MAX_MAP_CAP = 1 << 31
+
+// A `Map[K, V]` grows once more than `MAP_MAX_LOAD_NUM / MAP_MAX_LOAD_DEN` of its slots are full
+const (
+	MAP_MAX_LOAD_NUM = 7
+	MAP_MAX_LOAD_DEN = 8
+)
+
+// A single slot in the table of a `Map[K, V]`
+type mapEntry[K comparable, V any] struct {
+	key K
+	val V
+	// The distance of this entry from its ideal slot plus 1, or 0 if the slot is empty
+	psl uint32
+}
+
+// An open-addressing hash map using Robin Hood hashing, with its table stored in memory
+// from an `Allocator` so the garbage collector never scans it, no matter how many
+// entries it holds
+//
+// During insertion, an entry far from its ideal slot takes the place of any entry closer
+// to its own, keeping probe sequences short even at high load. Deletion shifts later entries
+// back instead of leaving tombstones, so lookups never slow down after many deletions
+//
+// Like a `List[T]`, the map caches the `Allocator` it was created with and owns its table.
+// Since the garbage collector cannot see inside the table, `K` and `V` MUST NOT hold the
+// only reference to any Golang heap memory
+//
+// The `Map[K, V]` is NOT safe for concurrent use
+type Map[K comparable, V any] struct {
+	entries *mapEntry[K, V]
+	len     uint32
+	cap     uint32
+	seed    maphash.Seed
+	alloc   Allocator
+}
+
+// Create a new `Map[K, V]` with room for at least `capacity` entries before it needs
+// to grow, using the provided `Allocator`
+func CreateMap[K comparable, V any](capacity int, alloc Allocator) Map[K, V] {
+	m, err := TryCreateMap[K, V](capacity, alloc)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return m
+}
+
+// Like `CreateMap()`, but returns an error instead of panicking if the
+// memory cannot be allocated
+func TryCreateMap[K comparable, V any](capacity int, alloc Allocator) (Map[K, V], error) {
+	checkLen("CreateMap()", capacity)
+	m := Map[K, V]{
+		seed:  maphash.MakeSeed(),
+		alloc: alloc,
+	}
+	err := m.tryResize(mapCapFor(capacity))
+	return m, err
+}
+
+// Return the number of slots needed to hold `len` entries without exceeding the max load
+//
+// The math is done in `uint64` so a large `len` cannot overflow before it is checked
+func mapCapFor(len int) int {
+	slots := max((uint64(len)*MAP_MAX_LOAD_DEN+MAP_MAX_LOAD_NUM-1)/MAP_MAX_LOAD_NUM, MIN_MAP_CAP)
+	newCap := uint64(1) << bits.Len64(slots-1)
+	if newCap > MAX_MAP_CAP || newCap > math.MaxInt {
+		panic(fmt.Sprintf("fatal: go_manual_memory: Map[K, V] growth: %d entries overflow the max capacity %d", len, uint64(min(MAX_MAP_CAP, math.MaxInt))))
+	}
+	return int(newCap)
+}
+
+func (m *Map[K, V]) table() []mapEntry[K, V] {
+	return unsafe.Slice(m.entries, m.cap)
+}
+
+func (m *Map[K, V]) hash(key K) uint64 {
+	return maphash.Comparable(m.seed, key)
+}
+
+// Return the index of the slot holding `key`
+func (m *Map[K, V]) find(key K) (idx uint32, found bool) {
+	if m.len == 0 {
+		return 0, false
+	}
+	table := m.table()
+	mask := m.cap - 1
+	idx = uint32(m.hash(key)) & mask
+	for psl := uint32(1); ; psl += 1 {
+		entry := &table[idx]
+		if entry.psl < psl {
+			return 0, false
+		}
+		if entry.psl == psl && entry.key == key {
+			return idx, true
+		}
+		idx = (idx + 1) & mask
+	}
+}
+
+// Insert an entry for a key that is not already in the map. There MUST be a free slot
+func (m *Map[K, V]) insertNew(entry mapEntry[K, V]) {
+	table := m.table()
+	mask := m.cap - 1
+	idx := uint32(m.hash(entry.key)) & mask
+	entry.psl = 1
+	for {
+		slot := &table[idx]
+		if slot.psl == 0 {
+			*slot = entry
+			m.len += 1
+			return
+		}
+		if slot.psl < entry.psl {
+			*slot, entry = entry, *slot
+		}
+		entry.psl += 1
+		idx = (idx + 1) & mask
+	}
+}
+
+// Move all entries into a new table with `newCap` slots, which MUST be a power of 2
+func (m *Map[K, V]) tryResize(newCap int) error {
+	checkLen("Map[K, V] growth", newCap)
+	newTable, err := TryAlloc[mapEntry[K, V]](m.alloc, newCap)
+	if err != nil {
+		return err
+	}
+	clear(newTable)
+	oldTable := m.table()
+	m.entries = unsafe.SliceData(newTable)
+	m.cap = clampCap(newCap)
+	m.len = 0
+	for i := range oldTable {
+		if oldTable[i].psl != 0 {
+			m.insertNew(oldTable[i])
+		}
+	}
+	if len(oldTable) > 0 {
+		Free(m.alloc, oldTable)
+	}
+	return nil
+}
+
+// Return the number of entries in the map
+//
+// Analogous to `len(map)`
+func (m *Map[K, V]) Len() int {
+	return int(m.len)
+}
+
+// Return the value stored for `key`, and whether it was found
+//
+// Analogous to `val, found := map[key]`
+func (m *Map[K, V]) Get(key K) (val V, found bool) {
+	idx, found := m.find(key)
+	if !found {
+		return val, false
+	}
+	return m.table()[idx].val, true
+}
+
+// Return a pointer to the value stored for `key`, or nil if it was not found
+//
+// The pointer becomes INVALID after the next `Put()` or `Delete()`
+func (m *Map[K, V]) GetPtr(key K) *V {
+	idx, found := m.find(key)
+	if !found {
+		return nil
+	}
+	return &m.table()[idx].val
+}
+
+// Return whether the map has an entry for `key`
+func (m *Map[K, V]) Has(key K) bool {
+	_, found := m.find(key)
+	return found
+}
+
+// Store `val` for `key`, replacing any existing value, and growing the map if neccessary
+//
+// Analogous to `map[key] = val`
+func (m *Map[K, V]) Put(key K, val V) {
+	if err := m.TryPut(key, val); err != nil {
+		panic("fatal: " + err.Error())
+	}
+}
+
+// Like `Put()`, but returns an error instead of panicking if the map
+// needs to grow and the memory cannot be allocated, in which case the map is unchanged
+func (m *Map[K, V]) TryPut(key K, val V) error {
+	if idx, found := m.find(key); found {
+		m.table()[idx].val = val
+		return nil
+	}
+	if (uint64(m.len)+1)*MAP_MAX_LOAD_DEN > uint64(m.cap)*MAP_MAX_LOAD_NUM {
+		if err := m.tryResize(mapCapFor(m.Len() + 1)); err != nil {
+			return err
+		}
+	}
+	m.insertNew(mapEntry[K, V]{
+		key: key,
+		val: val,
+	})
+	return nil
+}
+
+// Remove the entry for `key`, returning whether it was found
+//
+// Analogous to `delete(map, key)`
+func (m *Map[K, V]) Delete(key K) bool {
+	idx, found := m.find(key)
+	if !found {
+		return false
+	}
+	table := m.table()
+	mask := m.cap - 1
+	next := (idx + 1) & mask
+	for table[next].psl > 1 {
+		table[idx] = table[next]
+		table[idx].psl -= 1
+		idx = next
+		next = (next + 1) & mask
+	}
+	table[idx] = mapEntry[K, V]{}
+	m.len -= 1
+	return true
+}
+
+// Return an iterator over every key and value in the map, in no particular order
+//
+// The map MUST NOT be modified during iteration
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		table := m.table()
+		for i := range table {
+			if table[i].psl != 0 && !yield(table[i].key, table[i].val) {
+				return
+			}
+		}
+	}
+}
+
+// Return an iterator over every key in the map, in no particular order
+//
+// The map MUST NOT be modified during iteration
+func (m *Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for key := range m.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// Remove all entries from the map, keeping its capacity
+func (m *Map[K, V]) Clear() {
+	clear(m.table())
+	m.len = 0
+}
+
+// Destroy this `Map[K, V]`, returning the memory to the cached `Allocator`
+//
+// The caller MUST ensure the cached `Allocator` is the exact one originally used to
+// create the map
+func (m *Map[K, V]) Destroy() {
+	if m.cap > 0 {
+		Free(m.alloc, m.table())
+	}
+	m.entries = nil
+	m.len = 0
+	m.cap = 0
+	m.alloc = nil
+}
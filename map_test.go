@@ -0,0 +1,176 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+func TestMap(t *testing.T) {
+	parent := NewGoAllocator()
+	m := CreateMap[uint64, uint64](0, parent)
+	exp := make(map[uint64]uint64)
+	r := rand.New(rand.NewSource(1))
+	for i := range 50000 {
+		// Keys from a small range, so puts replace and deletes hit often
+		key := uint64(r.Intn(5000))
+		switch r.Intn(3) {
+		case 0, 1:
+			m.Put(key, uint64(i))
+			exp[key] = uint64(i)
+		default:
+			_, expFound := exp[key]
+			if found := m.Delete(key); found != expFound {
+				t.Fatalf("\ntest case failed: Delete() found\nKEY: %d\nEXP: %v\nGOT: %v\n", key, expFound, found)
+			}
+			delete(exp, key)
+		}
+		if m.Len() != len(exp) {
+			t.Fatalf("\ntest case failed: Len()\nEXP: %d\nGOT: %d\n", len(exp), m.Len())
+		}
+	}
+	for key := range uint64(5000) {
+		expVal, expFound := exp[key]
+		val, found := m.Get(key)
+		if found != expFound || val != expVal || m.Has(key) != expFound {
+			t.Fatalf("\ntest case failed: Get()\nKEY: %d\nEXP: %d, %v\nGOT: %d, %v\n", key, expVal, expFound, val, found)
+		}
+		if ptr := m.GetPtr(key); (ptr != nil) != expFound || (ptr != nil && *ptr != expVal) {
+			t.Fatalf("\ntest case failed: GetPtr()\nKEY: %d\n", key)
+		}
+	}
+	seen := 0
+	for key, val := range m.All() {
+		if expVal, found := exp[key]; !found || val != expVal {
+			t.Fatalf("\ntest case failed: All() entry\nKEY: %d\nEXP: %d, true\nGOT: %d, %v\n", key, expVal, val, found)
+		}
+		seen += 1
+	}
+	if seen != len(exp) {
+		t.Errorf("\ntest case failed: All() count\nEXP: %d\nGOT: %d\n", len(exp), seen)
+	}
+	m.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestMapOperations(t *testing.T) {
+	type point struct{ x, y int32 }
+	m := CreateMap[point, int](0, NewGoAllocator())
+	if _, found := m.Get(point{}); found || m.GetPtr(point{}) != nil || m.Delete(point{}) {
+		t.Errorf("\ntest case failed: lookup in an empty map\n")
+	}
+	m.Put(point{1, 2}, 3)
+	m.Put(point{2, 1}, 4)
+	m.Put(point{1, 2}, 5)
+	if val, _ := m.Get(point{1, 2}); val != 5 || m.Len() != 2 {
+		t.Errorf("\ntest case failed: Put() replacing a value\nVAL: %d\nLEN: %d\n", val, m.Len())
+	}
+	*m.GetPtr(point{2, 1}) += 10
+	if val, _ := m.Get(point{2, 1}); val != 14 {
+		t.Errorf("\ntest case failed: GetPtr() write\nEXP: 14\nGOT: %d\n", val)
+	}
+	keys := 0
+	for range m.Keys() {
+		keys += 1
+		break
+	}
+	if keys != 1 {
+		t.Errorf("\ntest case failed: Keys() did not stop early\nGOT: %d\n", keys)
+	}
+	for range m.All() {
+		keys += 1
+		break
+	}
+	if keys != 2 {
+		t.Errorf("\ntest case failed: All() did not stop early\nGOT: %d\n", keys)
+	}
+	capBefore := m.cap
+	m.Clear()
+	if m.Len() != 0 || m.Has(point{1, 2}) || m.cap != capBefore {
+		t.Errorf("\ntest case failed: Clear()\nLEN: %d\nCAP: %d\n", m.Len(), m.cap)
+	}
+	m.Put(point{3, 3}, 1)
+	if val, found := m.Get(point{3, 3}); !found || val != 1 {
+		t.Errorf("\ntest case failed: Put() after Clear()\n")
+	}
+	m.Destroy()
+	if m.Len() != 0 || m.cap != 0 {
+		t.Errorf("\ntest case failed: Destroy() did not reset the map\n")
+	}
+}
+
+func TestMapCapacity(t *testing.T) {
+	tests := []struct {
+		len int
+		cap int
+	}{
+		{0, MIN_MAP_CAP},
+		{7, MIN_MAP_CAP},
+		{8, 16},
+		{14, 16},
+		{15, 32},
+		{1000, 2048},
+	}
+	for _, test := range tests {
+		if got := mapCapFor(test.len); got != test.cap {
+			t.Errorf("\ntest case failed: mapCapFor()\nLEN: %d\nEXP: %d\nGOT: %d\n", test.len, test.cap, got)
+		}
+	}
+	if bits.UintSize == 64 {
+		// Converted at runtime, since the constants would overflow `int` on 32-bit platforms
+		maxCap := uint64(MAX_MAP_CAP)
+		if got := mapCapFor(int(maxCap / 2)); uint64(got) != maxCap {
+			t.Errorf("\ntest case failed: mapCapFor() at the max capacity\nEXP: %d\nGOT: %d\n", maxCap, got)
+		}
+		expectPanic(t, "mapCapFor() past MAX_MAP_CAP", func() { mapCapFor(int(maxCap)) })
+		expectPanic(t, "mapCapFor() with a len that overflows 32 bits", func() { mapCapFor(int(maxCap * 2)) })
+	}
+	m := CreateMap[uint64, uint64](1000, NewGoAllocator())
+	entries := m.entries
+	for i := range uint64(1000) {
+		m.Put(i, i)
+	}
+	if m.entries != entries {
+		t.Errorf("\ntest case failed: map created with capacity grew before reaching it\n")
+	}
+	m.Put(1000, 1000)
+	for i := range uint64(1001) {
+		if val, found := m.Get(i); !found || val != i {
+			t.Fatalf("\ntest case failed: value lost across growth\nKEY: %d\n", i)
+		}
+	}
+	m.Destroy()
+}
+
+func TestMapErrors(t *testing.T) {
+	alloc := failingAllocator{NewGoAllocator(), unsafe.Sizeof(mapEntry[uint64, uint64]{}) * MIN_MAP_CAP}
+	if _, err := TryCreateMap[uint64, uint64](MIN_MAP_CAP, alloc); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: TryCreateMap() over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	expectPanic(t, "CreateMap() over the limit", func() { CreateMap[uint64, uint64](MIN_MAP_CAP, alloc) })
+	m, err := TryCreateMap[uint64, uint64](0, alloc)
+	if err != nil {
+		t.Fatalf("\ntest case failed: TryCreateMap()\nGOT ERR: %v\n", err)
+	}
+	for i := range uint64(7) {
+		m.Put(i, i)
+	}
+	if err := m.TryPut(7, 7); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: TryPut() growth over the limit\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if m.Len() != 7 || m.Has(7) || m.cap != MIN_MAP_CAP {
+		t.Errorf("\ntest case failed: map changed by failed growth\nLEN: %d\nCAP: %d\n", m.Len(), m.cap)
+	}
+	expectPanic(t, "Put() growth over the limit", func() { m.Put(7, 7) })
+	if err := m.TryPut(3, 30); err != nil {
+		t.Errorf("\ntest case failed: TryPut() replacing a value in a full map\nGOT ERR: %v\n", err)
+	}
+	if SAFETY_CHECKS {
+		expectPanic(t, "CreateMap() with negative capacity", func() { CreateMap[uint64, uint64](-1, alloc) })
+	}
+	m.Destroy()
+}
@@ -1,12 +1,38 @@
 package go_manual_memory
 
-// import "unsafe"
+import "unsafe"
 
-// type MiniAddr uint32
+// A compact 32-bit address returned by a `MiniAllocator`
+//
+// The zero value `MINI_NIL` never refers to valid memory
+type MiniAddr uint32
 
-// type MiniAllocator interface {
-// 	RawAlloc(len, align uint32) (addr MiniAddr, alloc_len uint32)
-// 	RawResizeInPlace(addr MiniAddr, old_len, new_len uint32) (newAddr MiniAddr, success bool)
-// 	RawFree(addr MiniAddr, len uint32)
-// 	Expand(addr MiniAddr) unsafe.Pointer
-// }
+// A `MiniAddr` that does not refer to any memory, analogous to `nil`
+const MINI_NIL MiniAddr = 0
+
+type MiniAllocator interface {
+	RawAlloc(len, align uint32) (addr MiniAddr, alloc_len uint32)
+	RawResizeInPlace(addr MiniAddr, old_len, new_len uint32) (newAddr MiniAddr, success bool)
+	RawFree(addr MiniAddr, len uint32)
+	// Return the full pointer the compact address refers to
+	Expand(addr MiniAddr) unsafe.Pointer
+}
+
+// Create a single-item (scalar) value of type `T` and return its compact address
+func MiniCreate[T any](alloc MiniAllocator) MiniAddr {
+	size := unsafe.Sizeof(*new(T))
+	align := unsafe.Alignof(*new(T))
+	addr, _ := alloc.RawAlloc(uint32(size), uint32(align))
+	return addr
+}
+
+// Destroy (free) a single-item (scalar) value of type `T` at the compact address
+func MiniDestroy[T any](alloc MiniAllocator, addr MiniAddr) {
+	size := unsafe.Sizeof(*new(T))
+	alloc.RawFree(addr, uint32(size))
+}
+
+// Return a pointer to the value of type `T` at the compact address
+func MiniExpand[T any](alloc MiniAllocator, addr MiniAddr) *T {
+	return (*T)(alloc.Expand(addr))
+}
@@ -1,39 +1,113 @@
 package go_manual_memory
 
-// import "unsafe"
-
-// // This allocator ALWAYS returns addresses
-// type MiniSlabAllocator struct {
-// 	parentAlloc       Allocator
-// 	slabIds           Slice[uint32]
-// 	addresses         Slice[uintptr]
-// 	maxSlabSize       uint32
-// 	maxSlabSizeBits   uint32
-// 	maxSlabCount      uint32
-// 	slabIdMask        uint32
-// 	extendedSlabCount uint32
-// }
-
-// // Expand implements MiniAllocator.
-// func (m *MiniSlabAllocator) Expand(addr MiniAddr) unsafe.Pointer {
-// 	panic("unimplemented")
-// }
-
-// // RawAlloc implements MiniAllocator.
-// func (m *MiniSlabAllocator) RawAlloc(len uint32, align uint32) (addr MiniAddr, alloc_len uint32) {
-// 	uptr, ulen := m.parentAlloc.RawAlloc(uintptr(len), uintptr(align))
-// 	uaddr := uintptr(uptr)
-// 	lowAddr :=
-// }
-
-// // RawFree implements MiniAllocator.
-// func (m *MiniSlabAllocator) RawFree(addr MiniAddr, len uint32) {
-// 	panic("unimplemented")
-// }
-
-// // RawResizeInPlace implements MiniAllocator.
-// func (m *MiniSlabAllocator) RawResizeInPlace(addr MiniAddr, old_len uint32, new_len uint32) (newAddr MiniAddr, success bool) {
-// 	panic("unimplemented")
-// }
-
-// var _ MiniAllocator = (*MiniSlabAllocator)(nil)
+import (
+	"fmt"
+	"unsafe"
+)
+
+// The smallest number of bits a `MiniSlabAllocator` may use for the offset within a slab
+const MIN_MINI_SLAB_SIZE_BITS = 13
+
+// The largest number of bits a `MiniSlabAllocator` may use for the offset within a slab
+const MAX_MINI_SLAB_SIZE_BITS = 30
+
+// This allocator ALWAYS returns compact 32-bit addresses
+//
+// Memory is requested from the parent allocator in slabs of exactly `1 << maxSlabSizeBits`
+// bytes and carved into spans in the same way as the `SpanAllocator`. Each `MiniAddr`
+// stores the slab id in its upper bits and the offset into the slab in its lower
+// `maxSlabSizeBits` bits, so a larger slab size allows larger allocations but fewer slabs
+type MiniSlabAllocator struct {
+	spans           *SpanAllocator
+	maxSlabSize     uint32
+	maxSlabSizeBits uint32
+	maxSlabCount    uint32
+	offsetMask      uint32
+}
+
+// Create a new `MiniSlabAllocator` that requests slabs of `1 << maxSlabSizeBits` bytes
+// from the parent allocator
+//
+// `maxSlabSizeBits` MUST be in the range [`MIN_MINI_SLAB_SIZE_BITS`, `MAX_MINI_SLAB_SIZE_BITS`]
+func NewMiniSlabAllocator(parent Allocator, maxSlabSizeBits uint32) *MiniSlabAllocator {
+	if maxSlabSizeBits < MIN_MINI_SLAB_SIZE_BITS || maxSlabSizeBits > MAX_MINI_SLAB_SIZE_BITS {
+		panic(fmt.Sprintf("fatal: go_manual_memory: NewMiniSlabAllocator(): maxSlabSizeBits %d is outside the range [%d, %d]", maxSlabSizeBits, MIN_MINI_SLAB_SIZE_BITS, MAX_MINI_SLAB_SIZE_BITS))
+	}
+	maxSlabSize := uint32(1) << maxSlabSizeBits
+	// Slab id 0 is reserved so that `MINI_NIL` is never a valid address
+	maxSlabCount := (uint32(1) << (32 - maxSlabSizeBits)) - 1
+	return &MiniSlabAllocator{
+		spans:           newSpanAllocator(parent, uintptr(maxSlabSize), maxSlabCount),
+		maxSlabSize:     maxSlabSize,
+		maxSlabSizeBits: maxSlabSizeBits,
+		maxSlabCount:    maxSlabCount,
+		offsetMask:      maxSlabSize - 1,
+	}
+}
+
+func (m *MiniSlabAllocator) encode(ptr unsafe.Pointer) MiniAddr {
+	slab, _ := m.spans.chunkOf(ptr)
+	offset := uint32(uintptr(ptr) - m.spans.chunkAddr(slab))
+	return MiniAddr(((slab + 1) << m.maxSlabSizeBits) | offset)
+}
+
+// Expand implements MiniAllocator.
+func (m *MiniSlabAllocator) Expand(addr MiniAddr) unsafe.Pointer {
+	if addr == MINI_NIL {
+		return nil
+	}
+	slab := (uint32(addr) >> m.maxSlabSizeBits) - 1
+	offset := uint32(addr) & m.offsetMask
+	return unsafe.Add(unsafe.Pointer(unsafe.SliceData(m.spans.chunks[slab])), offset)
+}
+
+// RawAlloc implements MiniAllocator.
+func (m *MiniSlabAllocator) RawAlloc(len uint32, align uint32) (addr MiniAddr, alloc_len uint32) {
+	addr, alloc_len, err := m.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// Like `RawAlloc()`, but returns an error instead of panicking if the
+// memory cannot be allocated
+func (m *MiniSlabAllocator) TryRawAlloc(len uint32, align uint32) (addr MiniAddr, alloc_len uint32, err error) {
+	if len == 0 {
+		return MINI_NIL, 0, nil
+	}
+	if len > m.maxSlabSize || align > m.maxSlabSize {
+		return MINI_NIL, 0, fmt.Errorf("%w: MiniSlabAllocator.TryRawAlloc(): requested len %d (align %d) is greater than max slab size %d", ErrOutOfMemory, len, align, m.maxSlabSize)
+	}
+	ptr, ulen, err := m.spans.TryRawAlloc(uintptr(len), uintptr(align))
+	if err != nil {
+		return MINI_NIL, 0, err
+	}
+	return m.encode(ptr), uint32(ulen), nil
+}
+
+// RawFree implements MiniAllocator.
+func (m *MiniSlabAllocator) RawFree(addr MiniAddr, len uint32) {
+	if addr == MINI_NIL {
+		return
+	}
+	m.spans.RawFree(m.Expand(addr), uintptr(len))
+}
+
+// RawResizeInPlace implements MiniAllocator.
+func (m *MiniSlabAllocator) RawResizeInPlace(addr MiniAddr, old_len uint32, new_len uint32) (newAddr MiniAddr, success bool) {
+	if addr == MINI_NIL {
+		return addr, false
+	}
+	_, success = m.spans.RawResizeInPlace(m.Expand(addr), uintptr(old_len), uintptr(new_len))
+	return addr, success
+}
+
+// Return all slabs to the parent allocator
+//
+// All addresses returned from this allocator become invalid
+func (m *MiniSlabAllocator) Destroy() {
+	m.spans.Destroy()
+}
+
+var _ MiniAllocator = (*MiniSlabAllocator)(nil)
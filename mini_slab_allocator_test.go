@@ -0,0 +1,88 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+)
+
+type miniTestNode struct {
+	val  uint64
+	next MiniAddr
+}
+
+func TestMiniSlabAllocatorLinkedList(t *testing.T) {
+	m := NewMiniSlabAllocator(NewGoAllocator(), MIN_MINI_SLAB_SIZE_BITS)
+	const count = 20000
+	var head MiniAddr
+	for i := range count {
+		addr := MiniCreate[miniTestNode](m)
+		if addr == MINI_NIL {
+			t.Fatalf("\ntest case failed: MiniCreate() returned MINI_NIL\nIDX: %d\n", i)
+		}
+		node := MiniExpand[miniTestNode](m, addr)
+		node.val = uint64(i)
+		node.next = head
+		head = addr
+	}
+	if len(m.spans.chunks) < 2 {
+		t.Fatalf("\ntest case failed: expected the list to span several slabs\nGOT SLABS: %d\n", len(m.spans.chunks))
+	}
+	for i := count - 1; head != MINI_NIL; i -= 1 {
+		node := MiniExpand[miniTestNode](m, head)
+		if node.val != uint64(i) {
+			t.Fatalf("\ntest case failed: wrong value\nEXP: %d\nGOT: %d\n", i, node.val)
+		}
+		next := node.next
+		MiniDestroy[miniTestNode](m, head)
+		head = next
+	}
+	m.Destroy()
+}
+
+func TestMiniSlabAllocatorAddresses(t *testing.T) {
+	m := NewMiniSlabAllocator(NewGoAllocator(), MIN_MINI_SLAB_SIZE_BITS)
+	if m.Expand(MINI_NIL) != nil {
+		t.Errorf("\ntest case failed: Expand(MINI_NIL) is not nil\n")
+	}
+	if addr, _ := m.RawAlloc(0, 1); addr != MINI_NIL {
+		t.Errorf("\ntest case failed: zero length allocation\nEXP: MINI_NIL\nGOT: %d\n", addr)
+	}
+	addrs := make([]MiniAddr, 0, 10)
+	for range 10 {
+		addr, alloc_len := m.RawAlloc(1000, 64)
+		if alloc_len < 1000 {
+			t.Fatalf("\ntest case failed: alloc_len too small\nGOT: %d\n", alloc_len)
+		}
+		if uintptr(m.Expand(addr))&63 != 0 {
+			t.Fatalf("\ntest case failed: misaligned address\nADDR: %d\n", addr)
+		}
+		if m.encode(m.Expand(addr)) != addr {
+			t.Fatalf("\ntest case failed: address does not round trip\nEXP: %d\nGOT: %d\n", addr, m.encode(m.Expand(addr)))
+		}
+		addrs = append(addrs, addr)
+	}
+	if _, ok := m.RawResizeInPlace(addrs[len(addrs)-1], 1000, 2000); !ok {
+		t.Errorf("\ntest case failed: could not grow the last allocation into free space\n")
+	}
+	for _, addr := range addrs {
+		m.RawFree(addr, 1000)
+	}
+	m.Destroy()
+}
+
+func TestMiniSlabAllocatorErrors(t *testing.T) {
+	m := NewMiniSlabAllocator(NewGoAllocator(), MIN_MINI_SLAB_SIZE_BITS)
+	if _, _, err := m.TryRawAlloc(1<<MIN_MINI_SLAB_SIZE_BITS+1, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: larger than a slab\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	for _, bits := range []uint32{MIN_MINI_SLAB_SIZE_BITS - 1, MAX_MINI_SLAB_SIZE_BITS + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("\ntest case failed: no panic for out of range slab size bits\nBITS: %d\n", bits)
+				}
+			}()
+			NewMiniSlabAllocator(NewGoAllocator(), bits)
+		}()
+	}
+}
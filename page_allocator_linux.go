@@ -0,0 +1,285 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The size of a transparent huge page on most Linux systems
+const HUGE_PAGE_SIZE = 2 << 20
+
+// Not defined by the `syscall` package
+const _MADV_FREE = 8
+
+// Optional behavior for a `PageAllocator`
+type PageAllocatorOptions struct {
+	// Request transparent huge pages with `MADV_HUGEPAGE` for every allocation
+	// of at least `HugePageMinLen` bytes, aligning them to `HUGE_PAGE_SIZE`
+	HugePages bool
+	// The smallest allocation to request huge pages for, `HUGE_PAGE_SIZE` if 0
+	HugePageMinLen uintptr
+	// Keep up to this many bytes of freed mappings cached for reuse instead of unmapping
+	// them immediately. Memory reused from the cache is NOT guaranteed to be zeroed
+	CacheMaxBytes uintptr
+	// Once a cached mapping has been unused for this long, its pages are returned to the
+	// operating system with `madvise()`, while keeping the address range mapped for reuse
+	IdleThreshold time.Duration
+	// Return idle pages with `MADV_FREE` (lazily reclaimed) instead of `MADV_DONTNEED`
+	UseMadvFree bool
+}
+
+// This allocator requests memory directly from the operating system with `mmap()`,
+// instead of from the Golang heap. The memory is completely invisible to the garbage
+// collector, so it adds no scanning or heap-growth pressure no matter how large it is
+//
+// All allocations are rounded up to a multiple of `PAGE_SIZE`, so this allocator is best
+// used directly for very large buffers, or as the 'Parent' of the other allocators
+// in this package in place of the `GoAllocator`
+//
+// See `PageAllocatorOptions` for huge page support, and for caching freed mappings
+// and returning their pages to the operating system once they have been idle
+//
+// The `PageAllocator` is safe for concurrent use by multiple goroutines
+type PageAllocator struct {
+	opts        PageAllocatorOptions
+	mappedBytes atomic.Uint64
+	cacheMu     sync.Mutex
+	cache       []pageCacheEntry
+	cachedBytes uintptr
+}
+
+// A freed mapping kept for reuse
+type pageCacheEntry struct {
+	addr     uintptr
+	len      uintptr
+	freedAt  time.Time
+	released bool
+}
+
+// Create a new `PageAllocator` with default options
+func NewPageAllocator() *PageAllocator {
+	return NewPageAllocatorWithOptions(PageAllocatorOptions{})
+}
+
+// Create a new `PageAllocator` with the provided options
+func NewPageAllocatorWithOptions(opts PageAllocatorOptions) *PageAllocator {
+	if opts.HugePageMinLen == 0 {
+		opts.HugePageMinLen = HUGE_PAGE_SIZE
+	}
+	return &PageAllocator{
+		opts:  opts,
+		cache: make([]pageCacheEntry, 0),
+	}
+}
+
+// Return the total number of bytes currently mapped by this allocator,
+// including any freed mappings held in the cache
+func (p *PageAllocator) MappedBytes() uint64 {
+	return p.mappedBytes.Load()
+}
+
+// Return the number of bytes of freed mappings held in the cache
+func (p *PageAllocator) CachedBytes() uintptr {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	return p.cachedBytes
+}
+
+func mmapAnon(len uintptr) (addr uintptr, errno syscall.Errno) {
+	addr, _, errno = syscall.Syscall6(_SYS_MMAP, 0, len, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANONYMOUS, ^uintptr(0), 0)
+	return
+}
+
+func munmap(addr uintptr, len uintptr) {
+	if len == 0 {
+		return
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, addr, len, 0)
+	if errno != 0 {
+		panic(fmt.Sprintf("fatal: go_manual_memory: munmap(0x%x, %d) failed: %s", addr, len, errno))
+	}
+}
+
+func madvise(addr uintptr, len uintptr, advice int) {
+	// Advice is only a hint, so failures are ignored
+	syscall.Syscall(syscall.SYS_MADVISE, addr, len, uintptr(advice))
+}
+
+// Attempt to grow or shrink a mapping without moving it
+func mremapInPlace(addr uintptr, old_len uintptr, new_len uintptr) bool {
+	_, _, errno := syscall.Syscall6(syscall.SYS_MREMAP, addr, old_len, new_len, 0, 0, 0)
+	return errno == 0
+}
+
+// RawAlloc implements Allocator.
+func (p *PageAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := p.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (p *PageAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	alloc_len = alignForward(len, PAGE_SIZE)
+	huge := p.opts.HugePages && alloc_len >= p.opts.HugePageMinLen
+	if huge {
+		align = max(align, HUGE_PAGE_SIZE)
+	}
+	if addr, found := p.takeFromCache(alloc_len, align); found {
+		return UnsafeCast[uintptr, unsafe.Pointer](addr), alloc_len, nil
+	}
+	// mmap() only guarantees OS page alignment, so larger alignments are
+	// satisfied by over-mapping and trimming the excess from either end
+	extra := uintptr(0)
+	if align > uintptr(os.Getpagesize()) {
+		extra = align
+	}
+	addr, errno := mmapAnon(alloc_len + extra)
+	if errno != 0 {
+		return nil, 0, fmt.Errorf("%w: PageAllocator.TryRawAlloc(): mmap of %d bytes failed: %s", ErrOutOfMemory, alloc_len+extra, errno)
+	}
+	if extra > 0 {
+		aligned := alignForward(addr, align)
+		munmap(addr, aligned-addr)
+		munmap(aligned+alloc_len, addr+extra-aligned)
+		addr = aligned
+	}
+	if huge {
+		madvise(addr, alloc_len, syscall.MADV_HUGEPAGE)
+	}
+	p.mappedBytes.Add(uint64(alloc_len))
+	return UnsafeCast[uintptr, unsafe.Pointer](addr), alloc_len, nil
+}
+
+// RawFree implements Allocator.
+func (p *PageAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	len = alignForward(len, PAGE_SIZE)
+	if p.opts.CacheMaxBytes > 0 && len <= p.opts.CacheMaxBytes {
+		p.addToCache(uintptr(ptr), len)
+		return
+	}
+	munmap(uintptr(ptr), len)
+	p.mappedBytes.Add(-uint64(len))
+}
+
+// RawResizeInPlace implements Allocator.
+//
+// Growing uses `mremap()`, and only succeeds if the pages directly after the
+// mapping are not already in use
+func (p *PageAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	old_len = alignForward(old_len, PAGE_SIZE)
+	new_len = alignForward(new_len, PAGE_SIZE)
+	if new_len == old_len {
+		return ptr, true
+	}
+	if new_len < old_len {
+		munmap(uintptr(ptr)+new_len, old_len-new_len)
+		p.mappedBytes.Add(-uint64(old_len - new_len))
+		return ptr, true
+	}
+	if !mremapInPlace(uintptr(ptr), old_len, new_len) {
+		return ptr, false
+	}
+	p.mappedBytes.Add(uint64(new_len - old_len))
+	return ptr, true
+}
+
+// Reuse a cached mapping of at least `len` bytes at the requested alignment, if any,
+// returning any unused tail to the cache
+func (p *PageAllocator) takeFromCache(len uintptr, align uintptr) (addr uintptr, found bool) {
+	if p.opts.CacheMaxBytes == 0 {
+		return 0, false
+	}
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	for i, entry := range p.cache {
+		if entry.len < len || entry.addr%align != 0 {
+			continue
+		}
+		p.cachedBytes -= len
+		if entry.len == len {
+			p.cache = append(p.cache[:i], p.cache[i+1:]...)
+		} else {
+			p.cache[i].addr += len
+			p.cache[i].len -= len
+		}
+		return entry.addr, true
+	}
+	return 0, false
+}
+
+func (p *PageAllocator) addToCache(addr uintptr, len uintptr) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	p.cache = append(p.cache, pageCacheEntry{
+		addr:    addr,
+		len:     len,
+		freedAt: time.Now(),
+	})
+	p.cachedBytes += len
+	// Oldest entries are always at the front
+	for p.cachedBytes > p.opts.CacheMaxBytes {
+		oldest := p.cache[0]
+		munmap(oldest.addr, oldest.len)
+		p.mappedBytes.Add(-uint64(oldest.len))
+		p.cachedBytes -= oldest.len
+		p.cache = p.cache[1:]
+	}
+	p.scavenge(time.Now())
+}
+
+// Return the pages of idle cached mappings to the OS. Must be called with the lock held
+func (p *PageAllocator) scavenge(now time.Time) {
+	advice := syscall.MADV_DONTNEED
+	if p.opts.UseMadvFree {
+		advice = _MADV_FREE
+	}
+	for i := range p.cache {
+		entry := &p.cache[i]
+		if entry.released || now.Sub(entry.freedAt) < p.opts.IdleThreshold {
+			continue
+		}
+		madvise(entry.addr, entry.len, advice)
+		entry.released = true
+	}
+}
+
+// Return the pages of any cached mappings that have been idle for longer than
+// the idle threshold to the operating system
+//
+// This is also done automatically whenever memory is freed, but can be called
+// periodically to shrink RSS after a peak when no memory is being freed
+func (p *PageAllocator) Scavenge() {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	p.scavenge(time.Now())
+}
+
+// Unmap all cached mappings, returning them to the operating system entirely
+func (p *PageAllocator) FlushCache() {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	for _, entry := range p.cache {
+		munmap(entry.addr, entry.len)
+		p.mappedBytes.Add(-uint64(entry.len))
+	}
+	p.cache = p.cache[:0]
+	p.cachedBytes = 0
+}
+
+var _ FallibleAllocator = (*PageAllocator)(nil)
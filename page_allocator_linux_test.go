@@ -0,0 +1,188 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"errors"
+	"math/bits"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestPageAllocator(t *testing.T) {
+	p := NewPageAllocator()
+	live := stressAllocator(t, p, 1, 2000, PAGE_SIZE*4, PAGE_SIZE*8)
+	freeTestBlocks(p, live)
+	if p.MappedBytes() != 0 {
+		t.Errorf("\ntest case failed: bytes still mapped after freeing everything\nGOT: %d\n", p.MappedBytes())
+	}
+}
+
+func TestPageAllocatorAlloc(t *testing.T) {
+	p := NewPageAllocator()
+	if ptr, alloc_len := p.RawAlloc(0, 8); ptr != nil || alloc_len != 0 {
+		t.Errorf("\ntest case failed: zero length allocation\nGOT: %p, %d\n", ptr, alloc_len)
+	}
+	mem := Alloc[byte](p, 100)
+	if cap(mem) != PAGE_SIZE || p.MappedBytes() != PAGE_SIZE {
+		t.Errorf("\ntest case failed: allocation not rounded to a page\nCAP: %d\nMAPPED: %d\n", cap(mem), p.MappedBytes())
+	}
+	for i, b := range mem[:cap(mem)] {
+		if b != 0 {
+			t.Fatalf("\ntest case failed: new mapping not zeroed\nOFFSET: %d\nGOT: %d\n", i, b)
+		}
+	}
+	Free(p, mem)
+	ptr, alloc_len := p.RawAlloc(PAGE_SIZE, 1<<20)
+	if uintptr(ptr)&(1<<20-1) != 0 || alloc_len != PAGE_SIZE || p.MappedBytes() != PAGE_SIZE {
+		t.Errorf("\ntest case failed: over-aligned mapping\nADDR: 0x%x\nLEN: %d\nMAPPED: %d\n", uintptr(ptr), alloc_len, p.MappedBytes())
+	}
+	unsafe.Slice((*byte)(ptr), alloc_len)[alloc_len-1] = 1
+	p.RawFree(ptr, alloc_len)
+	if bits.UintSize == 64 {
+		huge := uintptr(1) << (bits.UintSize - 2)
+		if _, _, err := p.TryRawAlloc(huge, 8); !errors.Is(err, ErrOutOfMemory) {
+			t.Errorf("\ntest case failed: mapping larger than the address space\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+		}
+		expectPanic(t, "RawAlloc() larger than the address space", func() { p.RawAlloc(huge, 8) })
+	}
+	if p.MappedBytes() != 0 {
+		t.Errorf("\ntest case failed: bytes still mapped\nGOT: %d\n", p.MappedBytes())
+	}
+}
+
+func TestPageAllocatorResizeInPlace(t *testing.T) {
+	p := NewPageAllocator()
+	ptr, _ := p.RawAlloc(PAGE_SIZE*4, PAGE_SIZE)
+	mem := unsafe.Slice((*byte)(ptr), PAGE_SIZE*4)
+	mem[PAGE_SIZE-1] = 7
+	if newPtr, ok := p.RawResizeInPlace(ptr, PAGE_SIZE*4, PAGE_SIZE+1); !ok || newPtr != ptr {
+		t.Fatalf("\ntest case failed: shrink\nOK: %v\n", ok)
+	}
+	if p.MappedBytes() != PAGE_SIZE*2 {
+		t.Errorf("\ntest case failed: bytes mapped after shrink\nEXP: %d\nGOT: %d\n", PAGE_SIZE*2, p.MappedBytes())
+	}
+	if _, ok := p.RawResizeInPlace(ptr, PAGE_SIZE*2, PAGE_SIZE*2-1); !ok || p.MappedBytes() != PAGE_SIZE*2 {
+		t.Errorf("\ntest case failed: resize within the same page count\nOK: %v\nMAPPED: %d\n", ok, p.MappedBytes())
+	}
+	// The pages just unmapped by the shrink are free, so this can usually grow in place,
+	// unless another mapping has been placed there in the meantime
+	if _, ok := p.RawResizeInPlace(ptr, PAGE_SIZE*2, PAGE_SIZE*3); ok {
+		if p.MappedBytes() != PAGE_SIZE*3 {
+			t.Errorf("\ntest case failed: bytes mapped after grow\nEXP: %d\nGOT: %d\n", PAGE_SIZE*3, p.MappedBytes())
+		}
+		mem[PAGE_SIZE*3-1] = 1
+		if mem[PAGE_SIZE-1] != 7 {
+			t.Errorf("\ntest case failed: data lost by grow\nEXP: 7\nGOT: %d\n", mem[PAGE_SIZE-1])
+		}
+		p.RawFree(ptr, PAGE_SIZE*3)
+	} else {
+		p.RawFree(ptr, PAGE_SIZE*2)
+	}
+	if p.MappedBytes() != 0 {
+		t.Errorf("\ntest case failed: bytes still mapped\nGOT: %d\n", p.MappedBytes())
+	}
+}
+
+func TestPageAllocatorConcurrent(t *testing.T) {
+	p := NewPageAllocatorWithOptions(PageAllocatorOptions{
+		CacheMaxBytes: PAGE_SIZE * 64,
+	})
+	stressAllocatorConcurrent(t, p, 8, PAGE_SIZE*2, PAGE_SIZE)
+	p.FlushCache()
+	if p.MappedBytes() != 0 {
+		t.Errorf("\ntest case failed: bytes still mapped after flush\nGOT: %d\n", p.MappedBytes())
+	}
+}
+
+func TestPageAllocatorHugePages(t *testing.T) {
+	p := NewPageAllocatorWithOptions(PageAllocatorOptions{
+		HugePages:      true,
+		HugePageMinLen: HUGE_PAGE_SIZE / 2,
+	})
+	ptr, alloc_len := p.RawAlloc(HUGE_PAGE_SIZE/2, 8)
+	if uintptr(ptr)&(HUGE_PAGE_SIZE-1) != 0 || alloc_len != HUGE_PAGE_SIZE/2 {
+		t.Errorf("\ntest case failed: huge page allocation\nADDR: 0x%x\nLEN: %d\n", uintptr(ptr), alloc_len)
+	}
+	unsafe.Slice((*byte)(ptr), alloc_len)[alloc_len-1] = 1
+	p.RawFree(ptr, alloc_len)
+	small, small_len := p.RawAlloc(PAGE_SIZE, 8)
+	if small_len != PAGE_SIZE || p.MappedBytes() != PAGE_SIZE {
+		t.Errorf("\ntest case failed: small allocation with huge pages enabled\nLEN: %d\nMAPPED: %d\n", small_len, p.MappedBytes())
+	}
+	p.RawFree(small, small_len)
+	if NewPageAllocatorWithOptions(PageAllocatorOptions{HugePages: true}).opts.HugePageMinLen != HUGE_PAGE_SIZE {
+		t.Errorf("\ntest case failed: default huge page min len\n")
+	}
+}
+
+func TestPageAllocatorCache(t *testing.T) {
+	p := NewPageAllocatorWithOptions(PageAllocatorOptions{
+		CacheMaxBytes: PAGE_SIZE * 4,
+		IdleThreshold: time.Hour,
+	})
+	a := Alloc[byte](p, PAGE_SIZE*3)
+	a[0] = 1
+	Free(p, a)
+	if p.CachedBytes() != PAGE_SIZE*3 || p.MappedBytes() != PAGE_SIZE*3 {
+		t.Errorf("\ntest case failed: freed mapping not cached\nCACHED: %d\nMAPPED: %d\n", p.CachedBytes(), p.MappedBytes())
+	}
+	// Reused from the front of the cached mapping, which was never released, so the old data remains
+	b := Alloc[byte](p, PAGE_SIZE)
+	if &b[0] != &a[0] || b[0] != 1 || p.CachedBytes() != PAGE_SIZE*2 {
+		t.Errorf("\ntest case failed: cached mapping not reused\nEXP: %p\nGOT: %p\nCACHED: %d\n", &a[0], &b[0], p.CachedBytes())
+	}
+	c := Alloc[byte](p, PAGE_SIZE*2)
+	if &c[0] != &a[PAGE_SIZE] || p.CachedBytes() != 0 || p.MappedBytes() != PAGE_SIZE*3 {
+		t.Errorf("\ntest case failed: cached tail not reused\nEXP: %p\nGOT: %p\nCACHED: %d\n", &a[PAGE_SIZE], &c[0], p.CachedBytes())
+	}
+	Free(p, b)
+	Free(p, c)
+	big := Alloc[byte](p, PAGE_SIZE*3)
+	Free(p, big)
+	// The oldest cached mappings are unmapped to stay under the limit
+	if p.CachedBytes() != PAGE_SIZE*3 || p.MappedBytes() != PAGE_SIZE*3 {
+		t.Errorf("\ntest case failed: cache over the limit\nCACHED: %d\nMAPPED: %d\n", p.CachedBytes(), p.MappedBytes())
+	}
+	huge := Alloc[byte](p, PAGE_SIZE*5)
+	Free(p, huge)
+	if p.CachedBytes() != PAGE_SIZE*3 || p.MappedBytes() != PAGE_SIZE*3 {
+		t.Errorf("\ntest case failed: mapping larger than the cache was cached\nCACHED: %d\nMAPPED: %d\n", p.CachedBytes(), p.MappedBytes())
+	}
+	p.FlushCache()
+	if p.CachedBytes() != 0 || p.MappedBytes() != 0 {
+		t.Errorf("\ntest case failed: bytes left after flush\nCACHED: %d\nMAPPED: %d\n", p.CachedBytes(), p.MappedBytes())
+	}
+}
+
+func TestPageAllocatorIdleRelease(t *testing.T) {
+	for _, useMadvFree := range []bool{false, true} {
+		p := NewPageAllocatorWithOptions(PageAllocatorOptions{
+			CacheMaxBytes: PAGE_SIZE * 4,
+			IdleThreshold: 10 * time.Millisecond,
+			UseMadvFree:   useMadvFree,
+		})
+		a := Alloc[byte](p, PAGE_SIZE)
+		a[0] = 1
+		Free(p, a)
+		if p.cache[0].released {
+			t.Errorf("\ntest case failed: mapping released before idle threshold\nMADV_FREE: %v\n", useMadvFree)
+		}
+		time.Sleep(20 * time.Millisecond)
+		p.Scavenge()
+		if !p.cache[0].released {
+			t.Errorf("\ntest case failed: idle mapping not released\nMADV_FREE: %v\n", useMadvFree)
+		}
+		b := Alloc[byte](p, PAGE_SIZE)
+		if &b[0] != &a[0] {
+			t.Errorf("\ntest case failed: released mapping not reused\nMADV_FREE: %v\n", useMadvFree)
+		}
+		// MADV_FREE pages are only reclaimed under memory pressure, so may still hold old data
+		if !useMadvFree && b[0] != 0 {
+			t.Errorf("\ntest case failed: MADV_DONTNEED pages not zeroed\nGOT: %d\n", b[0])
+		}
+		Free(p, b)
+		p.FlushCache()
+	}
+}
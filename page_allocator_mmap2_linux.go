@@ -0,0 +1,10 @@
+//go:build linux && (386 || arm)
+
+package go_manual_memory
+
+import "syscall"
+
+// On 32-bit platforms `SYS_MMAP` is the legacy `old_mmap()`, which takes a pointer
+// to its arguments, so `mmap2()` is used instead. The offset is always 0, so its
+// different units do not matter
+const _SYS_MMAP = syscall.SYS_MMAP2
@@ -0,0 +1,7 @@
+//go:build linux && !(386 || arm)
+
+package go_manual_memory
+
+import "syscall"
+
+const _SYS_MMAP = syscall.SYS_MMAP
@@ -0,0 +1,210 @@
+package go_manual_memory
+
+import "unsafe"
+
+// This allocator hands out fixed-size slots large enough to hold a single value of type `T`,
+// keeping freed slots in an intrusive free list stored inside the freed memory itself
+//
+// Both `Create()` and `Destroy()` are O(1), making this allocator well suited for
+// node-heavy structures like trees and linked lists
+//
+// When used as an `Allocator`, any request larger than a slot is forwarded to the parent
+// allocator, and freed based on the length passed to `RawFree()`. Requests that fit in a
+// slot but need more alignment than a slot provides are also forwarded, and remembered
+// so they can be returned to the parent when freed
+type PoolAllocator[T any] struct {
+	parentAlloc Allocator
+	slotSize    uintptr
+	slotAlign   uintptr
+	blockSize   uintptr
+	blocks      [][]byte
+	freeHead    unsafe.Pointer
+	bumpNext    uintptr
+	bumpEnd     uintptr
+	overAligned map[uintptr]struct{}
+}
+
+// Create a new `PoolAllocator[T]` that requests blocks of memory from the parent allocator
+// and splits them into slots for values of type `T`
+func NewPoolAllocator[T any](parent Allocator) *PoolAllocator[T] {
+	slotAlign := max(unsafe.Alignof(*new(T)), unsafe.Alignof(unsafe.Pointer(nil)))
+	slotSize := alignForward(max(unsafe.Sizeof(*new(T)), unsafe.Sizeof(unsafe.Pointer(nil))), slotAlign)
+	return &PoolAllocator[T]{
+		parentAlloc: parent,
+		slotSize:    slotSize,
+		slotAlign:   slotAlign,
+		blockSize:   alignForward(max(PAGE_SIZE, slotSize), PAGE_SIZE),
+		blocks:      make([][]byte, 0),
+		overAligned: make(map[uintptr]struct{}),
+	}
+}
+
+// Return the size in bytes of every slot in this pool
+func (p *PoolAllocator[T]) SlotSize() uintptr {
+	return p.slotSize
+}
+
+func (p *PoolAllocator[T]) addNewBlock() error {
+	ptr, alloc_len, err := TryRawAlloc(p.parentAlloc, p.blockSize, p.slotAlign)
+	if err != nil {
+		return err
+	}
+	block := unsafe.Slice((*byte)(ptr), alloc_len)
+	p.blocks = append(p.blocks, block)
+	p.bumpNext = 0
+	p.bumpEnd = alloc_len - (alloc_len % p.slotSize)
+	return nil
+}
+
+func (p *PoolAllocator[T]) allocSlot() (unsafe.Pointer, error) {
+	if p.freeHead != nil {
+		ptr := p.freeHead
+		p.freeHead = *(*unsafe.Pointer)(ptr)
+		return ptr, nil
+	}
+	if p.bumpNext >= p.bumpEnd {
+		if err := p.addNewBlock(); err != nil {
+			return nil, err
+		}
+	}
+	block := p.blocks[len(p.blocks)-1]
+	ptr := unsafe.Pointer(&block[p.bumpNext])
+	p.bumpNext += p.slotSize
+	return ptr, nil
+}
+
+// Return whether `ptr` is a slot-sized allocation that was forwarded to the parent
+func (p *PoolAllocator[T]) isOverAligned(ptr unsafe.Pointer) bool {
+	if len(p.overAligned) == 0 {
+		return false
+	}
+	_, found := p.overAligned[uintptr(ptr)]
+	return found
+}
+
+func (p *PoolAllocator[T]) freeSlot(ptr unsafe.Pointer) {
+	storeUnsafePtr((*unsafe.Pointer)(ptr), p.freeHead)
+	p.freeHead = ptr
+}
+
+// Create a new zeroed value of type `T` from the pool
+func (p *PoolAllocator[T]) Create() *T {
+	ptr, err := p.TryCreate()
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return ptr
+}
+
+// Like `Create()`, but returns an error instead of panicking if the
+// pool cannot get a new block from the parent allocator
+func (p *PoolAllocator[T]) TryCreate() (*T, error) {
+	slot, err := p.allocSlot()
+	if err != nil {
+		return nil, err
+	}
+	ptr := (*T)(slot)
+	*ptr = *new(T)
+	return ptr, nil
+}
+
+// Return a value of type `T` to the pool
+//
+// The caller MUST ensure the pointer was originally returned from `Create()` on this pool
+func (p *PoolAllocator[T]) Destroy(ptr *T) {
+	if ptr == nil {
+		return
+	}
+	p.freeSlot(unsafe.Pointer(ptr))
+}
+
+// RawAlloc implements Allocator.
+func (p *PoolAllocator[T]) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := p.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (p *PoolAllocator[T]) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len > p.slotSize {
+		return TryRawAlloc(p.parentAlloc, len, align)
+	}
+	if align > p.slotAlign {
+		ptr, alloc_len, err = TryRawAlloc(p.parentAlloc, len, align)
+		if err != nil {
+			return nil, 0, err
+		}
+		p.overAligned[uintptr(ptr)] = struct{}{}
+		// Any larger length would be freed straight to the parent without forgetting `ptr`
+		return ptr, min(alloc_len, p.slotSize), nil
+	}
+	ptr, err = p.allocSlot()
+	if err != nil {
+		return nil, 0, err
+	}
+	return ptr, p.slotSize, nil
+}
+
+// RawFree implements Allocator.
+func (p *PoolAllocator[T]) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	if len > p.slotSize {
+		p.parentAlloc.RawFree(ptr, len)
+		return
+	}
+	if p.isOverAligned(ptr) {
+		delete(p.overAligned, uintptr(ptr))
+		p.parentAlloc.RawFree(ptr, len)
+		return
+	}
+	p.freeSlot(ptr)
+}
+
+// RawResizeInPlace implements Allocator.
+func (p *PoolAllocator[T]) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if old_len > p.slotSize {
+		if new_len <= p.slotSize {
+			return ptr, false
+		}
+		return p.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	if new_len > p.slotSize {
+		return ptr, false
+	}
+	if p.isOverAligned(ptr) {
+		return p.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	return ptr, true
+}
+
+// Owns implements Owner.
+func (p *PoolAllocator[T]) Owns(ptr unsafe.Pointer) bool {
+	for _, block := range p.blocks {
+		if blockContains(block, ptr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return all blocks to the parent allocator
+//
+// All values created from this pool become invalid. Any allocations forwarded
+// to the parent allocator are NOT freed
+func (p *PoolAllocator[T]) Release() {
+	for _, block := range p.blocks {
+		p.parentAlloc.RawFree(unsafe.Pointer(unsafe.SliceData(block)), uintptr(cap(block)))
+	}
+	p.blocks = p.blocks[:0]
+	p.freeHead = nil
+	p.bumpNext = 0
+	p.bumpEnd = 0
+}
+
+var _ FallibleAllocator = (*PoolAllocator[byte])(nil)
+var _ Owner = (*PoolAllocator[byte])(nil)
@@ -0,0 +1,99 @@
+package go_manual_memory
+
+import (
+	"testing"
+	"unsafe"
+)
+
+type poolTestNode struct {
+	val         int
+	left, right uint32
+}
+
+func TestPoolAllocatorCreateDestroy(t *testing.T) {
+	parent := NewGoAllocator()
+	p := NewPoolAllocator[poolTestNode](parent)
+	nodes := make([]*poolTestNode, 0, 10000)
+	for i := range 10000 {
+		node := p.Create()
+		if *node != (poolTestNode{}) {
+			t.Fatalf("\ntest case failed: Create() did not zero the value\nGOT: %+v\n", *node)
+		}
+		node.val = i
+		nodes = append(nodes, node)
+	}
+	for i := 0; i < len(nodes); i += 2 {
+		p.Destroy(nodes[i])
+	}
+	blockCount := len(p.blocks)
+	for range len(nodes) / 2 {
+		Create[poolTestNode](p).val = -1
+	}
+	if len(p.blocks) != blockCount {
+		t.Errorf("\ntest case failed: freed slots were not reused\nEXP BLOCKS: %d\nGOT BLOCKS: %d\n", blockCount, len(p.blocks))
+	}
+	for i := 1; i < len(nodes); i += 2 {
+		if nodes[i].val != i {
+			t.Fatalf("\ntest case failed: live value overwritten\nEXP: %d\nGOT: %d\n", i, nodes[i].val)
+		}
+	}
+	last := nodes[len(nodes)-1]
+	Destroy(p, last)
+	if again := Create[poolTestNode](p); again != last {
+		t.Errorf("\ntest case failed: most recently freed slot was not reused first\nEXP: %p\nGOT: %p\n", last, again)
+	}
+	p.Release()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: blocks left in parent after Release()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestPoolAllocatorAsAllocator(t *testing.T) {
+	p := NewPoolAllocator[poolTestNode](NewGoAllocator())
+	live := stressAllocator(t, p, 1, 5000, p.SlotSize()*2, 64)
+	freeTestBlocks(p, live)
+	p.Release()
+}
+
+func TestPoolAllocatorForwarding(t *testing.T) {
+	parent := NewGoAllocator()
+	p := NewPoolAllocator[uint64](parent)
+	slot, _ := p.RawAlloc(8, unsafe.Alignof(uint64(0)))
+	if !p.Owns(slot) {
+		t.Errorf("\ntest case failed: slot not owned\n")
+	}
+	large, large_len := p.RawAlloc(100, 8)
+	if p.Owns(large) || large_len < 100 {
+		t.Errorf("\ntest case failed: large request not forwarded\nOWNED: %v\nLEN: %d\n", p.Owns(large), large_len)
+	}
+	aligned, aligned_len, err := p.TryRawAlloc(8, 64)
+	if err != nil {
+		t.Fatalf("\ntest case failed: over-aligned request\nGOT ERR: %v\n", err)
+	}
+	if uintptr(aligned)&63 != 0 || p.Owns(aligned) || aligned_len > p.SlotSize() {
+		t.Errorf("\ntest case failed: over-aligned request not forwarded\nADDR: 0x%x\nOWNED: %v\nLEN: %d\n", uintptr(aligned), p.Owns(aligned), aligned_len)
+	}
+	if _, ok := p.RawResizeInPlace(aligned, 8, 16); ok {
+		t.Errorf("\ntest case failed: over-aligned allocation grew past a slot\n")
+	}
+	liveCount := parent.LiveCount()
+	p.RawFree(aligned, aligned_len)
+	p.RawFree(large, 100)
+	if parent.LiveCount() != liveCount-2 {
+		t.Errorf("\ntest case failed: forwarded allocations not returned to parent\nEXP: %d\nGOT: %d\n", liveCount-2, parent.LiveCount())
+	}
+	if next, _ := p.RawAlloc(8, unsafe.Alignof(uint64(0))); next == aligned {
+		t.Errorf("\ntest case failed: over-aligned allocation was pushed on the free list\n")
+	}
+	p.RawFree(slot, 8)
+	p.Release()
+}
+
+func TestPoolAllocatorSlotSize(t *testing.T) {
+	if size := NewPoolAllocator[byte](NewGoAllocator()).SlotSize(); size != unsafe.Sizeof(unsafe.Pointer(nil)) {
+		t.Errorf("\ntest case failed: slot too small for the free list link\nEXP: %d\nGOT: %d\n", unsafe.Sizeof(unsafe.Pointer(nil)), size)
+	}
+	if size := NewPoolAllocator[[3]uint64](NewGoAllocator()).SlotSize(); size != 24 {
+		t.Errorf("\ntest case failed: slot size\nEXP: 24\nGOT: %d\n", size)
+	}
+}
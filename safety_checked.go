@@ -0,0 +1,59 @@
+//go:build !gomm_unchecked
+
+package go_manual_memory
+
+import (
+	"fmt"
+	"math"
+)
+
+// Whether the length and bounds checks on `Slice[T]`, `SubSlice[T]`, `List[T]`, and the
+// other containers are compiled in. They are on by default, and can be removed by building with
+// `-tags gomm_unchecked` once a program is known to be correct
+//
+// Without the checks, negative lengths and lengths that overflow `uint32` are silently
+// truncated, shrinking a `List[T]` by more than its length wraps around, and out of range
+// `Deque[T]` indexes wrap around to other values. A sub-slice end past the length always
+// panics, but a negative start or a start greater than the end only panics with the less
+// descriptive Golang runtime message
+const SAFETY_CHECKS = true
+
+// Panic if `n` is not a valid length for a `Slice[T]`, `SubSlice[T]`, or `List[T]`
+func checkLen(method string, n int) {
+	if n < 0 {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: negative length %d", method, n))
+	}
+	if uint64(n) > math.MaxUint32 {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: length %d overflows the max length %d", method, n, uint32(math.MaxUint32)))
+	}
+}
+
+// Panic if `start` and `end` are not valid sub-slice bounds for a length of `len`
+func checkSubSlice(method string, start, end, len int) {
+	if start < 0 {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: negative start index %d", method, start))
+	}
+	if start > end {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: start index %d is greater than end index %d", method, start, end))
+	}
+	if end > len {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: end index %d is greater than len %d", method, end, len))
+	}
+}
+
+// Panic if a length of `len` cannot be shrunk by `shrink`
+func checkShrink(method string, shrink, len int) {
+	if shrink > len {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: cannot shrink len %d by %d", method, len, shrink))
+	}
+}
+
+// Panic if `idx` is not a valid index for a length of `len`
+//
+// Only needed where an out of range index would not be caught by the Golang runtime,
+// like the wrapping indexes of a `Deque[T]`
+func checkIndex(method string, idx, len int) {
+	if idx < 0 || idx >= len {
+		panic(fmt.Sprintf("fatal: go_manual_memory: %s: index %d out of range for len %d", method, idx, len))
+	}
+}
@@ -0,0 +1,15 @@
+//go:build gomm_unchecked
+
+package go_manual_memory
+
+// Whether the length and bounds checks on `Slice[T]`, `SubSlice[T]`, `List[T]`, and the
+// other containers are compiled in. See the checked build for details
+const SAFETY_CHECKS = false
+
+func checkLen(method string, n int) {}
+
+func checkSubSlice(method string, start, end, len int) {}
+
+func checkShrink(method string, shrink, len int) {}
+
+func checkIndex(method string, idx, len int) {}
@@ -0,0 +1,78 @@
+package go_manual_memory
+
+import "unsafe"
+
+// This allocator sends every request of up to `Threshold` bytes to `Small`,
+// and every larger request to `Large`
+//
+// Frees and resizes are routed by length in the same way. The `alloc_len` returned
+// for a small request never exceeds `Threshold`, so freeing with either the requested
+// length or `alloc_len` always reaches the same allocator, and resizing in place
+// across the threshold always fails. Create one directly with a struct literal
+// or with `NewSegregator()`
+//
+// The `Segregator` is only safe for concurrent use if both wrapped allocators are
+type Segregator struct {
+	Threshold uintptr
+	Small     Allocator
+	Large     Allocator
+}
+
+// Create a new `Segregator` that sends requests of up to `threshold` bytes
+// to `small` and all others to `large`
+func NewSegregator(threshold uintptr, small Allocator, large Allocator) *Segregator {
+	return &Segregator{
+		Threshold: threshold,
+		Small:     small,
+		Large:     large,
+	}
+}
+
+// RawAlloc implements Allocator.
+func (s *Segregator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := s.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (s *Segregator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len > s.Threshold {
+		return TryRawAlloc(s.Large, len, align)
+	}
+	ptr, alloc_len, err = TryRawAlloc(s.Small, len, align)
+	return ptr, min(alloc_len, s.Threshold), err
+}
+
+// RawFree implements Allocator.
+func (s *Segregator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if len > s.Threshold {
+		s.Large.RawFree(ptr, len)
+		return
+	}
+	s.Small.RawFree(ptr, len)
+}
+
+// RawResizeInPlace implements Allocator.
+func (s *Segregator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if old_len > s.Threshold {
+		if new_len <= s.Threshold {
+			return ptr, false
+		}
+		return s.Large.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	if new_len > s.Threshold {
+		return ptr, false
+	}
+	return s.Small.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// Owns implements Owner.
+func (s *Segregator) Owns(ptr unsafe.Pointer) bool {
+	return Owns(s.Small, ptr) || Owns(s.Large, ptr)
+}
+
+var _ FallibleAllocator = (*Segregator)(nil)
+var _ Owner = (*Segregator)(nil)
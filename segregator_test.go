@@ -0,0 +1,56 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestSegregator(t *testing.T) {
+	small := NewGoAllocator()
+	large := NewGoAllocator()
+	s := NewSegregator(64, small, large)
+	a, a_len := s.RawAlloc(64, 8)
+	if !small.Owns(a) || a_len != 64 {
+		t.Errorf("\ntest case failed: request at the threshold\nSMALL: %v\nEXP LEN: 64\nGOT LEN: %d\n", small.Owns(a), a_len)
+	}
+	b, b_len := s.RawAlloc(65, 8)
+	if !large.Owns(b) || b_len < 65 {
+		t.Errorf("\ntest case failed: request over the threshold\nLARGE: %v\nLEN: %d\n", large.Owns(b), b_len)
+	}
+	if !s.Owns(a) || !s.Owns(b) {
+		t.Errorf("\ntest case failed: allocations not owned\nA: %v\nB: %v\n", s.Owns(a), s.Owns(b))
+	}
+	var local [16]byte
+	if s.Owns(unsafe.Pointer(&local)) {
+		t.Errorf("\ntest case failed: foreign pointer is owned\n")
+	}
+	if _, ok := s.RawResizeInPlace(a, 64, 32); !ok {
+		t.Errorf("\ntest case failed: resize below the threshold\n")
+	}
+	if _, ok := s.RawResizeInPlace(a, 32, 65); ok {
+		t.Errorf("\ntest case failed: resize across the threshold from small\n")
+	}
+	if _, ok := s.RawResizeInPlace(b, 65, 64); ok {
+		t.Errorf("\ntest case failed: resize across the threshold from large\n")
+	}
+	if _, ok := s.RawResizeInPlace(b, 65, b_len); !ok {
+		t.Errorf("\ntest case failed: resize above the threshold\n")
+	}
+	// Freeing with `alloc_len` must reach the same allocator as the requested length
+	s.RawFree(a, a_len)
+	s.RawFree(b, b_len)
+	if small.LiveCount() != 0 || large.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: frees not routed by length\nSMALL: %d\nLARGE: %d\n", small.LiveCount(), large.LiveCount())
+	}
+}
+
+func TestSegregatorErrors(t *testing.T) {
+	s := NewSegregator(64, failingAllocator{NewGoAllocator(), 32}, failingAllocator{NewGoAllocator(), 128})
+	for _, len := range []uintptr{33, 129} {
+		if _, _, err := s.TryRawAlloc(len, 8); !errors.Is(err, ErrOutOfMemory) {
+			t.Errorf("\ntest case failed: wrapped allocator fails\nLEN: %d\nEXP ERR: %v\nGOT ERR: %v\n", len, ErrOutOfMemory, err)
+		}
+	}
+	expectPanic(t, "RawAlloc() when the wrapped allocator fails", func() { s.RawAlloc(129, 8) })
+}
@@ -0,0 +1,230 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// The directory holding the files of named shared-memory regions
+const SHARED_MEMORY_DIR = "/dev/shm"
+
+// How long `OpenSharedAllocator()` waits for another process to finish
+// initializing a region it is creating at the same time
+const SHARED_INIT_TIMEOUT = time.Second
+
+// This allocator manages a named shared-memory region (a file in `SHARED_MEMORY_DIR`
+// mapped with `MAP_SHARED`), so that multiple local processes can allocate from and
+// read each other's data with zero copies
+//
+// It uses the same file layout as a `FileAllocator`, so allocations are addressed by their
+// offset `A` into the region rather than a pointer, since each process maps the region at a
+// different address. Pass offsets (and lengths) between processes, and use `Expand()` or
+// `ExpandSharedSlice()` in each process to access the data. Offset 0 is never a valid
+// allocation, analogous to `nil`
+//
+// Unlike a `FileAllocator`, the region has a fixed length and is never remapped, so
+// pointers returned from `Expand()` remain valid until `Close()`. Allocations fail
+// with `ErrOutOfMemory` once the region is full
+//
+// The free list is protected by a spinlock stored in the region header, making the
+// `SharedAllocator` safe for concurrent use by multiple goroutines in multiple processes.
+// A process that dies while holding the lock (only possible during an allocator call)
+// leaves the region locked forever. The data itself is NOT protected, so the processes
+// must agree on who may read or write each allocation, for example by passing offsets
+// over a pipe or socket only once the data is ready
+type SharedAllocator[A Address] struct {
+	heap *FileAllocator[A]
+}
+
+// Open the named shared-memory region as a `SharedAllocator`, creating it with at least
+// `size` bytes if it does not exist. If the region already exists `size` is ignored
+//
+// An existing region MUST have been created by a `SharedAllocator` with the same address type `A`
+func OpenSharedAllocator[A Address](name string, size uintptr) (*SharedAllocator[A], error) {
+	path := filepath.Join(SHARED_MEMORY_DIR, name)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err == nil {
+		heap, err := initFileAllocator[A](file, size)
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		heap.fixedLen = true
+		return &SharedAllocator[A]{heap: heap}, nil
+	}
+	if !errors.Is(err, fs.ErrExist) {
+		return nil, err
+	}
+	file, err = os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	heap, err := waitForSharedInit[A](file)
+	if err != nil {
+		return nil, err
+	}
+	heap.fixedLen = true
+	return &SharedAllocator[A]{heap: heap}, nil
+}
+
+// Map a region that may still be being initialized by the process that created it.
+// The file is closed if an error is returned
+func waitForSharedInit[A Address](file *os.File) (*FileAllocator[A], error) {
+	deadline := time.Now().Add(SHARED_INIT_TIMEOUT)
+	for {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if info.Size() >= FILE_HEADER_SIZE {
+			heap := &FileAllocator[A]{
+				file: file,
+			}
+			if err = heap.mapFile(uintptr(info.Size())); err != nil {
+				file.Close()
+				return nil, err
+			}
+			for atomic.LoadUint32(&heap.header().version) == 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if err = heap.checkHeader(uintptr(info.Size())); err != nil {
+				heap.Close()
+				return nil, err
+			}
+			return heap, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("go_manual_memory: OpenSharedAllocator(): region %s was never initialized", file.Name())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Remove the named shared-memory region. Processes that already have it open
+// can keep using it, and the memory is released once they have all closed it
+func RemoveSharedAllocator(name string) error {
+	return os.Remove(filepath.Join(SHARED_MEMORY_DIR, name))
+}
+
+func (s *SharedAllocator[A]) lock() {
+	lock := &s.heap.header().lock
+	for !atomic.CompareAndSwapUint32(lock, 0, 1) {
+		runtime.Gosched()
+	}
+}
+
+func (s *SharedAllocator[A]) unlock() {
+	atomic.StoreUint32(&s.heap.header().lock, 0)
+}
+
+// Return the total length of the region in bytes, including the header
+func (s *SharedAllocator[A]) Len() uintptr {
+	return uintptr(len(s.heap.data))
+}
+
+// Expand the offset into a pointer to the data in this process's mapping of the region
+//
+// The pointer remains valid until `Close()`
+func (s *SharedAllocator[A]) Expand(off A) unsafe.Pointer {
+	return s.heap.Expand(off)
+}
+
+// Return a `Slice[T]` of the `len` values stored at the offset in this process's
+// mapping of the region
+//
+// The slice remains valid until `Close()`, and MUST NOT be destroyed with
+// `Slice[T].Destroy()`, use `DestroySharedSlice()` instead
+func ExpandSharedSlice[T any, A Address](s *SharedAllocator[A], off A, len int) Slice[T] {
+	return sliceFromSlice(unsafe.Slice((*T)(s.Expand(off)), len))
+}
+
+// Allocate room for `len` values of type `T` in the region, returning both the offset to
+// pass to other processes and a `Slice[T]` to access the values in this process
+//
+// The memory is NOT zeroed if it was previously freed
+func CreateSharedSlice[T any, A Address](s *SharedAllocator[A], len int) (off A, slice Slice[T]) {
+	off, slice, err := TryCreateSharedSlice[T](s, len)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// Like `CreateSharedSlice()`, but returns an error instead of panicking if the region is full
+func TryCreateSharedSlice[T any, A Address](s *SharedAllocator[A], len int) (off A, slice Slice[T], err error) {
+	size := unsafe.Sizeof(*new(T)) * uintptr(len)
+	off, _, err = s.TryRawAlloc(size, unsafe.Alignof(*new(T)))
+	if err != nil {
+		return 0, Slice[T]{}, err
+	}
+	return off, ExpandSharedSlice[T](s, off, len), nil
+}
+
+// Return the `len` values stored at the offset to the region's free list
+func DestroySharedSlice[T any, A Address](s *SharedAllocator[A], off A, len int) {
+	s.RawFree(off, unsafe.Sizeof(*new(T))*uintptr(len))
+}
+
+// Return the offset stored as the 'root' of the region, which processes can use to
+// find the first data stored in it without any other channel
+func (s *SharedAllocator[A]) Root() A {
+	s.lock()
+	defer s.unlock()
+	return s.heap.Root()
+}
+
+// Set the offset stored as the 'root' of the region
+func (s *SharedAllocator[A]) SetRoot(off A) {
+	s.lock()
+	defer s.unlock()
+	s.heap.SetRoot(off)
+}
+
+// RawAlloc allocates `len` bytes from the region, returning the offset of the allocation
+func (s *SharedAllocator[A]) RawAlloc(len uintptr, align uintptr) (off A, alloc_len uintptr) {
+	off, alloc_len, err := s.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// Like `RawAlloc()`, but returns an error instead of panicking if the region is full
+func (s *SharedAllocator[A]) TryRawAlloc(len uintptr, align uintptr) (off A, alloc_len uintptr, err error) {
+	s.lock()
+	defer s.unlock()
+	return s.heap.TryRawAlloc(len, align)
+}
+
+// RawFree returns the `len` bytes at the offset to the free list
+func (s *SharedAllocator[A]) RawFree(off A, len uintptr) {
+	s.lock()
+	defer s.unlock()
+	s.heap.RawFree(off, len)
+}
+
+// RawResizeInPlace attempts to grow or shrink the allocation at the offset without moving it
+func (s *SharedAllocator[A]) RawResizeInPlace(off A, old_len uintptr, new_len uintptr) (newOff A, success bool) {
+	s.lock()
+	defer s.unlock()
+	return s.heap.RawResizeInPlace(off, old_len, new_len)
+}
+
+// Unmap and close the region in this process. The region itself remains until
+// it is removed with `RemoveSharedAllocator()`
+//
+// All pointers returned from `Expand()` and slices returned from `ExpandSharedSlice()` become invalid
+func (s *SharedAllocator[A]) Close() error {
+	return s.heap.Close()
+}
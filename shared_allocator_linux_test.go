@@ -0,0 +1,142 @@
+//go:build linux
+
+package go_manual_memory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func sharedTestName(t *testing.T) string {
+	name := fmt.Sprintf("go_manual_memory_%s_%d", t.Name(), os.Getpid())
+	t.Cleanup(func() { RemoveSharedAllocator(name) })
+	return name
+}
+
+func TestSharedAllocator(t *testing.T) {
+	name := sharedTestName(t)
+	a, err := OpenSharedAllocator[uint32](name, PAGE_SIZE*4)
+	if err != nil {
+		t.Fatalf("\ntest case failed: create\nGOT ERR: %v\n", err)
+	}
+	defer a.Close()
+	// A second mapping of the same region stands in for another process
+	b, err := OpenSharedAllocator[uint32](name, 0)
+	if err != nil {
+		t.Fatalf("\ntest case failed: open existing\nGOT ERR: %v\n", err)
+	}
+	defer b.Close()
+	if a.Len() != PAGE_SIZE*4 || b.Len() != PAGE_SIZE*4 {
+		t.Errorf("\ntest case failed: region length\nEXP: %d\nGOT: %d, %d\n", PAGE_SIZE*4, a.Len(), b.Len())
+	}
+	off, slice := CreateSharedSlice[uint64](a, 10)
+	for i := range slice.Len() {
+		*slice.GetPtr(i) = uint64(i * i)
+	}
+	a.SetRoot(off)
+	if b.Root() != off {
+		t.Fatalf("\ntest case failed: root\nEXP: %d\nGOT: %d\n", off, b.Root())
+	}
+	other := ExpandSharedSlice[uint64](b, b.Root(), 10)
+	if other.GetPtr(0) == slice.GetPtr(0) {
+		t.Errorf("\ntest case failed: both handles share one mapping\n")
+	}
+	for i := range other.Len() {
+		if *other.GetPtr(i) != uint64(i*i) {
+			t.Fatalf("\ntest case failed: data not shared\nEXP: %d\nGOT: %d\n", i*i, *other.GetPtr(i))
+		}
+	}
+	if _, ok := b.RawResizeInPlace(off, 80, 160); !ok {
+		t.Errorf("\ntest case failed: grow into free space\n")
+	}
+	DestroySharedSlice[uint64](b, off, 20)
+	if again, _ := a.RawAlloc(80, 8); again != off {
+		t.Errorf("\ntest case failed: space freed by the other handle was not reused\nEXP: %d\nGOT: %d\n", off, again)
+	}
+}
+
+func TestSharedAllocatorFull(t *testing.T) {
+	a, err := OpenSharedAllocator[uint32](sharedTestName(t), PAGE_SIZE)
+	if err != nil {
+		t.Fatalf("\ntest case failed: create\nGOT ERR: %v\n", err)
+	}
+	defer a.Close()
+	if _, _, err := TryCreateSharedSlice[byte](a, PAGE_SIZE); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: allocation larger than the region\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	expectPanic(t, "CreateSharedSlice() larger than the region", func() { CreateSharedSlice[byte](a, PAGE_SIZE) })
+	if a.Len() != PAGE_SIZE {
+		t.Errorf("\ntest case failed: region grew\nEXP: %d\nGOT: %d\n", PAGE_SIZE, a.Len())
+	}
+	if _, _, err := TryCreateSharedSlice[byte](a, PAGE_SIZE-FILE_HEADER_SIZE); err != nil {
+		t.Errorf("\ntest case failed: allocation of the whole region\nGOT ERR: %v\n", err)
+	}
+}
+
+func TestSharedAllocatorConcurrent(t *testing.T) {
+	name := sharedTestName(t)
+	const workers = 8
+	handles := make([]*SharedAllocator[uint32], workers)
+	for w := range handles {
+		s, err := OpenSharedAllocator[uint32](name, PAGE_SIZE*64)
+		if err != nil {
+			t.Fatalf("\ntest case failed: open\nGOT ERR: %v\n", err)
+		}
+		defer s.Close()
+		handles[w] = s
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for w, s := range handles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				off, slice := CreateSharedSlice[uint32](s, i%32+1)
+				for j := range slice.Len() {
+					*slice.GetPtr(j) = uint32(w)
+				}
+				for j := range slice.Len() {
+					if *slice.GetPtr(j) != uint32(w) {
+						errs[w] = fmt.Errorf("\ntest case failed: allocation overwritten\nEXP: %d\nGOT: %d\n", w, *slice.GetPtr(j))
+						return
+					}
+				}
+				DestroySharedSlice[uint32](s, off, i%32+1)
+			}
+		}()
+	}
+	wg.Wait()
+	for w, err := range errs {
+		if err != nil {
+			t.Errorf("\nWORKER: %d%v", w, err)
+		}
+	}
+	if free := checkFileFreeList(t, handles[0].heap); free != PAGE_SIZE*64-FILE_HEADER_SIZE {
+		t.Errorf("\ntest case failed: free bytes after freeing everything\nEXP: %d\nGOT: %d\n", PAGE_SIZE*64-FILE_HEADER_SIZE, free)
+	}
+}
+
+func TestSharedAllocatorErrors(t *testing.T) {
+	name := sharedTestName(t)
+	a, err := OpenSharedAllocator[uint32](name, PAGE_SIZE)
+	if err != nil {
+		t.Fatalf("\ntest case failed: create\nGOT ERR: %v\n", err)
+	}
+	a.Close()
+	if _, err := OpenSharedAllocator[uint64](name, 0); err == nil {
+		t.Errorf("\ntest case failed: open with a different address size\n")
+	}
+	if _, err := OpenSharedAllocator[uint16](sharedTestName(t)+"_large", 1<<17); err == nil {
+		t.Errorf("\ntest case failed: size past the max address\n")
+	}
+	if err := RemoveSharedAllocator(name); err != nil {
+		t.Errorf("\ntest case failed: remove\nGOT ERR: %v\n", err)
+	}
+	if err := RemoveSharedAllocator(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("\ntest case failed: remove missing region\nEXP ERR: %v\nGOT ERR: %v\n", os.ErrNotExist, err)
+	}
+}
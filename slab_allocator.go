@@ -0,0 +1,272 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"math/bits"
+	"unsafe"
+)
+
+// The smallest size class handled by a `SlabAllocator`
+const MIN_SLAB_OBJECT_SIZE = 8
+
+// The largest size class handled by a `SlabAllocator`, larger requests
+// are forwarded to its parent
+const MAX_SLAB_OBJECT_SIZE = 4096
+
+// The number of power-of-2 size classes between `MIN_SLAB_OBJECT_SIZE` and `MAX_SLAB_OBJECT_SIZE`
+const NUM_SLAB_SIZE_CLASSES = 10
+
+// The size of each slab a `SlabAllocator` requests from its parent. Slabs are
+// also aligned to this size, so the slab holding any slot can be found by
+// rounding the slot's address down
+const SLAB_SIZE = PAGE_SIZE * 8
+
+// Stored at the start of every slab
+type slabHeader struct {
+	prev      *slabHeader
+	next      *slabHeader
+	freeHead  unsafe.Pointer
+	class     uint32
+	used      uint32
+	bumpNext  uint32
+	slotCount uint32
+}
+
+// Return the offset of the first slot in a slab of the size class
+func slabFirstSlot(class int) uintptr {
+	return alignForward(unsafe.Sizeof(slabHeader{}), slabClassSize(class))
+}
+
+func slabSizeClass(len uintptr) int {
+	len = max(len, MIN_SLAB_OBJECT_SIZE)
+	return bits.Len(uint(len-1)) - bits.Len(uint(MIN_SLAB_OBJECT_SIZE-1))
+}
+
+func slabClassSize(class int) uintptr {
+	return MIN_SLAB_OBJECT_SIZE << class
+}
+
+// Return the slab holding `ptr`, if it was allocated from this allocator
+//
+// The slab is looked up by address instead of rounding `ptr` down to a pointer, since
+// `ptr` may come from anywhere, and a rounded-down pointer could point at nothing
+func (s *SlabAllocator) slabOf(ptr unsafe.Pointer) (slab *slabHeader, found bool) {
+	slab, found = s.slabs[uintptr(ptr)&^(SLAB_SIZE-1)]
+	return
+}
+
+// This allocator keeps a separate list of slabs for each power-of-2 size class from
+// `MIN_SLAB_OBJECT_SIZE` to `MAX_SLAB_OBJECT_SIZE`, and hands out slots from them,
+// making it well suited for workloads with many small objects of mixed sizes
+//
+// Each slab is a `SLAB_SIZE` block aligned to its own size, with a header at the start, so
+// a freed slot is returned to its slab in O(1) time by rounding its address down. Slabs that
+// become completely empty are returned to the parent allocator, except for one kept per size
+// class to avoid thrashing. Since every slab is requested with `SLAB_SIZE` alignment, the
+// parent should be one that can provide that alignment cheaply, like a `PageAllocator`
+//
+// Requests larger than `MAX_SLAB_OBJECT_SIZE` are forwarded to the parent allocator,
+// and freed based on the length passed to `RawFree()`. Requests that need more alignment
+// than `MAX_SLAB_OBJECT_SIZE` are also forwarded, and remembered so they can be returned
+// to the parent when freed
+//
+// The `SlabAllocator` is NOT safe for concurrent use
+type SlabAllocator struct {
+	parentAlloc Allocator
+	// Every slab, by address
+	slabs       map[uintptr]*slabHeader
+	overAligned map[uintptr]struct{}
+	// Slabs of each size class that have at least one free slot
+	partial [NUM_SLAB_SIZE_CLASSES]*slabHeader
+	// A completely empty slab of each size class kept for reuse
+	empty [NUM_SLAB_SIZE_CLASSES]*slabHeader
+}
+
+// Create a new `SlabAllocator` that requests slabs from the parent allocator
+func NewSlabAllocator(parent Allocator) *SlabAllocator {
+	return &SlabAllocator{
+		parentAlloc: parent,
+		slabs:       make(map[uintptr]*slabHeader),
+		overAligned: make(map[uintptr]struct{}),
+	}
+}
+
+func (s *SlabAllocator) pushPartial(slab *slabHeader) {
+	storePtr(&slab.prev, nil)
+	storePtr(&slab.next, s.partial[slab.class])
+	if slab.next != nil {
+		storePtr(&slab.next.prev, slab)
+	}
+	s.partial[slab.class] = slab
+}
+
+func (s *SlabAllocator) removePartial(slab *slabHeader) {
+	if slab.prev == nil {
+		s.partial[slab.class] = slab.next
+	} else {
+		storePtr(&slab.prev.next, slab.next)
+	}
+	if slab.next != nil {
+		storePtr(&slab.next.prev, slab.prev)
+	}
+	storePtr(&slab.prev, nil)
+	storePtr(&slab.next, nil)
+}
+
+func (s *SlabAllocator) addNewSlab(class int) error {
+	if slab := s.empty[class]; slab != nil {
+		s.empty[class] = nil
+		s.pushPartial(slab)
+		return nil
+	}
+	ptr, _, err := TryRawAlloc(s.parentAlloc, SLAB_SIZE, SLAB_SIZE)
+	if err != nil {
+		return err
+	}
+	slab := (*slabHeader)(ptr)
+	// Set each field on its own, since storing a whole `slabHeader` would
+	// use write barriers for the pointer fields
+	storeUnsafePtr(&slab.freeHead, nil)
+	slab.class = uint32(class)
+	slab.used = 0
+	slab.bumpNext = uint32(slabFirstSlot(class))
+	slab.slotCount = uint32((SLAB_SIZE - slabFirstSlot(class)) / slabClassSize(class))
+	s.slabs[uintptr(ptr)] = slab
+	s.pushPartial(slab)
+	return nil
+}
+
+func (s *SlabAllocator) releaseSlab(slab *slabHeader) {
+	delete(s.slabs, uintptr(unsafe.Pointer(slab)))
+	s.parentAlloc.RawFree(unsafe.Pointer(slab), SLAB_SIZE)
+}
+
+// RawAlloc implements Allocator.
+func (s *SlabAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := s.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (s *SlabAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	if len > MAX_SLAB_OBJECT_SIZE {
+		return TryRawAlloc(s.parentAlloc, len, align)
+	}
+	if align > MAX_SLAB_OBJECT_SIZE {
+		ptr, alloc_len, err = TryRawAlloc(s.parentAlloc, len, align)
+		if err != nil {
+			return nil, 0, err
+		}
+		s.overAligned[uintptr(ptr)] = struct{}{}
+		// Any larger length would be freed straight to the parent without forgetting `ptr`
+		return ptr, min(alloc_len, MAX_SLAB_OBJECT_SIZE), nil
+	}
+	class := slabSizeClass(max(len, align))
+	if s.partial[class] == nil {
+		if err = s.addNewSlab(class); err != nil {
+			return nil, 0, err
+		}
+	}
+	slab := s.partial[class]
+	if slab.freeHead != nil {
+		ptr = slab.freeHead
+		storeUnsafePtr(&slab.freeHead, *(*unsafe.Pointer)(ptr))
+	} else {
+		ptr = unsafe.Add(unsafe.Pointer(slab), slab.bumpNext)
+		slab.bumpNext += uint32(slabClassSize(class))
+	}
+	slab.used += 1
+	if slab.used == slab.slotCount {
+		s.removePartial(slab)
+	}
+	return ptr, slabClassSize(class), nil
+}
+
+// RawFree implements Allocator.
+func (s *SlabAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	if len > MAX_SLAB_OBJECT_SIZE {
+		s.parentAlloc.RawFree(ptr, len)
+		return
+	}
+	slab, found := s.slabOf(ptr)
+	if !found {
+		if _, found = s.overAligned[uintptr(ptr)]; found {
+			delete(s.overAligned, uintptr(ptr))
+			s.parentAlloc.RawFree(ptr, len)
+			return
+		}
+		panic(fmt.Sprintf("fatal: go_manual_memory: SlabAllocator.RawFree(): pointer 0x%x was not allocated by this allocator", uintptr(ptr)))
+	}
+	if slab.used == slab.slotCount {
+		s.pushPartial(slab)
+	}
+	storeUnsafePtr((*unsafe.Pointer)(ptr), slab.freeHead)
+	storeUnsafePtr(&slab.freeHead, ptr)
+	slab.used -= 1
+	if slab.used == 0 {
+		s.removePartial(slab)
+		class := slab.class
+		storeUnsafePtr(&slab.freeHead, nil)
+		slab.bumpNext = uint32(slabFirstSlot(int(class)))
+		if s.empty[class] == nil {
+			s.empty[class] = slab
+		} else {
+			s.releaseSlab(slab)
+		}
+	}
+}
+
+// RawResizeInPlace implements Allocator.
+//
+// Succeeds as long as the new length fits in the same slot
+func (s *SlabAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if old_len > MAX_SLAB_OBJECT_SIZE {
+		if new_len <= MAX_SLAB_OBJECT_SIZE {
+			return ptr, false
+		}
+		return s.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	if new_len > MAX_SLAB_OBJECT_SIZE {
+		return ptr, false
+	}
+	slab, found := s.slabOf(ptr)
+	if !found {
+		if _, found = s.overAligned[uintptr(ptr)]; found {
+			return s.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+		}
+		return ptr, false
+	}
+	return ptr, new_len <= slabClassSize(int(slab.class))
+}
+
+// Owns implements Owner.
+func (s *SlabAllocator) Owns(ptr unsafe.Pointer) bool {
+	_, found := s.slabOf(ptr)
+	return found
+}
+
+// Return all slabs to the parent allocator
+//
+// All memory allocated from this allocator becomes invalid. Any allocations
+// forwarded to the parent allocator are NOT freed
+func (s *SlabAllocator) Destroy() {
+	for _, slab := range s.slabs {
+		s.parentAlloc.RawFree(unsafe.Pointer(slab), SLAB_SIZE)
+	}
+	clear(s.slabs)
+	clear(s.overAligned)
+	s.partial = [NUM_SLAB_SIZE_CLASSES]*slabHeader{}
+	s.empty = [NUM_SLAB_SIZE_CLASSES]*slabHeader{}
+}
+
+var _ FallibleAllocator = (*SlabAllocator)(nil)
+var _ Owner = (*SlabAllocator)(nil)
@@ -0,0 +1,169 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestSlabAllocator(t *testing.T) {
+	parent := NewGoAllocator()
+	s := NewSlabAllocator(parent)
+	live := stressAllocator(t, s, 1, 20000, MAX_SLAB_OBJECT_SIZE*2, MAX_SLAB_OBJECT_SIZE*2)
+	freeTestBlocks(s, live)
+	if len(s.overAligned) != 0 {
+		t.Errorf("\ntest case failed: over-aligned allocations not forgotten\nGOT: %d\n", len(s.overAligned))
+	}
+	// Only the one empty slab kept per size class may be left
+	for class, partial := range s.partial {
+		if partial != nil {
+			t.Errorf("\ntest case failed: partial slab left after freeing everything\nCLASS: %d\n", class)
+		}
+	}
+	if len(s.slabs) > NUM_SLAB_SIZE_CLASSES {
+		t.Errorf("\ntest case failed: empty slabs not released\nEXP MAX: %d\nGOT: %d\n", NUM_SLAB_SIZE_CLASSES, len(s.slabs))
+	}
+	s.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestSlabAllocatorSizeClasses(t *testing.T) {
+	tests := []struct {
+		len   uintptr
+		class int
+	}{
+		{1, 0},
+		{MIN_SLAB_OBJECT_SIZE, 0},
+		{MIN_SLAB_OBJECT_SIZE + 1, 1},
+		{100, 4},
+		{MAX_SLAB_OBJECT_SIZE, NUM_SLAB_SIZE_CLASSES - 1},
+	}
+	for _, test := range tests {
+		if class := slabSizeClass(test.len); class != test.class {
+			t.Errorf("\ntest case failed: size class\nLEN: %d\nEXP: %d\nGOT: %d\n", test.len, test.class, class)
+		}
+	}
+	s := NewSlabAllocator(NewGoAllocator())
+	ptr, alloc_len := s.RawAlloc(100, 8)
+	if alloc_len != 128 {
+		t.Errorf("\ntest case failed: alloc_len\nEXP: 128\nGOT: %d\n", alloc_len)
+	}
+	if _, ok := s.RawResizeInPlace(ptr, 100, 128); !ok {
+		t.Errorf("\ntest case failed: resize within size class\n")
+	}
+	if _, ok := s.RawResizeInPlace(ptr, 128, 129); ok {
+		t.Errorf("\ntest case failed: resize past size class\n")
+	}
+	if _, ok := s.RawResizeInPlace(ptr, 128, MAX_SLAB_OBJECT_SIZE+1); ok {
+		t.Errorf("\ntest case failed: resize past MAX_SLAB_OBJECT_SIZE\n")
+	}
+	// Alignment picks a larger size class, whose slots are aligned to their size
+	aligned, aligned_len := s.RawAlloc(16, 256)
+	if uintptr(aligned)&255 != 0 || aligned_len != 256 {
+		t.Errorf("\ntest case failed: aligned slot\nADDR: 0x%x\nEXP LEN: 256\nGOT LEN: %d\n", uintptr(aligned), aligned_len)
+	}
+	s.RawFree(aligned, 16)
+	s.RawFree(ptr, 128)
+	if again, _ := s.RawAlloc(128, 8); again != ptr {
+		t.Errorf("\ntest case failed: freed slot was not reused\nEXP: %p\nGOT: %p\n", ptr, again)
+	}
+	s.Destroy()
+}
+
+func TestSlabAllocatorEmptySlabs(t *testing.T) {
+	parent := NewGoAllocator()
+	s := NewSlabAllocator(parent)
+	const slotLen = 1024
+	perSlab := int((SLAB_SIZE - slabFirstSlot(slabSizeClass(slotLen))) / slotLen)
+	ptrs := make([]unsafe.Pointer, 0, perSlab*3)
+	for range perSlab * 3 {
+		ptr, _ := s.RawAlloc(slotLen, 8)
+		ptrs = append(ptrs, ptr)
+	}
+	if len(s.slabs) != 3 {
+		t.Fatalf("\ntest case failed: slab count\nEXP: 3\nGOT: %d\n", len(s.slabs))
+	}
+	for _, ptr := range ptrs {
+		s.RawFree(ptr, slotLen)
+	}
+	// One empty slab is kept, the rest go back to the parent
+	if len(s.slabs) != 1 || parent.LiveCount() != 1 {
+		t.Errorf("\ntest case failed: empty slabs not released\nEXP: 1\nGOT SLABS: %d\nGOT PARENT: %d\n", len(s.slabs), parent.LiveCount())
+	}
+	kept := s.empty[slabSizeClass(slotLen)]
+	if kept == nil {
+		t.Fatalf("\ntest case failed: no empty slab kept\n")
+	}
+	ptr, _ := s.RawAlloc(slotLen, 8)
+	if slab, _ := s.slabOf(ptr); slab != kept {
+		t.Errorf("\ntest case failed: kept slab not reused\nEXP: %p\nGOT: %p\n", kept, slab)
+	}
+	if uintptr(ptr) != uintptr(unsafe.Pointer(kept))+slabFirstSlot(slabSizeClass(slotLen)) {
+		t.Errorf("\ntest case failed: reused slab did not start from its first slot\nGOT: 0x%x\n", uintptr(ptr))
+	}
+	s.RawFree(ptr, slotLen)
+	s.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestSlabAllocatorForwarding(t *testing.T) {
+	parent := NewGoAllocator()
+	s := NewSlabAllocator(parent)
+	small, _ := s.RawAlloc(16, 16)
+	if !s.Owns(small) {
+		t.Errorf("\ntest case failed: slot not owned\n")
+	}
+	large, large_len := s.RawAlloc(MAX_SLAB_OBJECT_SIZE+1, 8)
+	if s.Owns(large) || large_len < MAX_SLAB_OBJECT_SIZE+1 {
+		t.Errorf("\ntest case failed: large request not forwarded\nOWNED: %v\nLEN: %d\n", s.Owns(large), large_len)
+	}
+	aligned, aligned_len, err := s.TryRawAlloc(16, MAX_SLAB_OBJECT_SIZE*2)
+	if err != nil {
+		t.Fatalf("\ntest case failed: request aligned past the largest size class\nGOT ERR: %v\n", err)
+	}
+	if uintptr(aligned)&(MAX_SLAB_OBJECT_SIZE*2-1) != 0 || s.Owns(aligned) || aligned_len > MAX_SLAB_OBJECT_SIZE {
+		t.Errorf("\ntest case failed: over-aligned request not forwarded\nADDR: 0x%x\nOWNED: %v\nLEN: %d\n", uintptr(aligned), s.Owns(aligned), aligned_len)
+	}
+	if _, ok := s.RawResizeInPlace(aligned, 16, aligned_len); !ok {
+		t.Errorf("\ntest case failed: resize of an over-aligned allocation within alloc_len\n")
+	}
+	liveCount := parent.LiveCount()
+	s.RawFree(aligned, aligned_len)
+	s.RawFree(large, large_len)
+	if parent.LiveCount() != liveCount-2 {
+		t.Errorf("\ntest case failed: forwarded allocations not returned to parent\nEXP: %d\nGOT: %d\n", liveCount-2, parent.LiveCount())
+	}
+	if len(s.overAligned) != 0 {
+		t.Errorf("\ntest case failed: over-aligned allocation not forgotten\nGOT: %d\n", len(s.overAligned))
+	}
+	s.RawFree(small, 16)
+	s.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestSlabAllocatorErrors(t *testing.T) {
+	s := NewSlabAllocator(failingAllocator{parentAlloc: NewGoAllocator(), maxLen: SLAB_SIZE - 1})
+	if _, _, err := s.TryRawAlloc(16, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: parent cannot allocate a slab\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if _, _, err := s.TryRawAlloc(16, MAX_SLAB_OBJECT_SIZE*2); err != nil || len(s.overAligned) != 1 {
+		t.Errorf("\ntest case failed: over-aligned request\nGOT ERR: %v\nGOT TRACKED: %d\n", err, len(s.overAligned))
+	}
+	if _, _, err := s.TryRawAlloc(SLAB_SIZE, MAX_SLAB_OBJECT_SIZE*2); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: parent cannot allocate a large request\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	s = NewSlabAllocator(NewGoAllocator())
+	var local [16]byte
+	expectPanic(t, "freeing a pointer from outside the allocator", func() {
+		s.RawFree(unsafe.Pointer(&local), 16)
+	})
+	if s.Owns(unsafe.Pointer(&local)) {
+		t.Errorf("\ntest case failed: foreign pointer is owned\n")
+	}
+}
@@ -14,16 +14,18 @@ type Slice[T any] struct {
 }
 
 func sliceFromSlice[T any](slice []T) Slice[T] {
+	checkLen("sliceFromSlice()", len(slice))
 	return Slice[T]{
 		ptr: unsafe.SliceData(slice),
 		len: uint32(len(slice)),
-		cap: uint32(cap(slice)),
+		cap: clampCap(cap(slice)),
 	}
 }
 
 // Copies the data from provided Golang slice into a new `Slice[T]`
 // using the provided `Allocator`
 func CreateSliceCopyFrom[T any](data []T, alloc Allocator) Slice[T] {
+	checkLen("CreateSliceCopyFrom()", len(data))
 	slice := Alloc[T](alloc, len(data))
 	copy(slice, data)
 	return sliceFromSlice(slice)
@@ -31,12 +33,22 @@ func CreateSliceCopyFrom[T any](data []T, alloc Allocator) Slice[T] {
 
 // Cretes a new `Slice[T]` with specified length, using provided `Allocator`
 func CreateSlice[T any](sliceLen int, alloc Allocator) Slice[T] {
+	checkLen("CreateSlice()", sliceLen)
 	slice := Alloc[T](alloc, sliceLen)
 	return sliceFromSlice(slice)
 }
 
+// Like `CreateSlice()`, but returns an error instead of panicking if the
+// memory cannot be allocated
+func TryCreateSlice[T any](sliceLen int, alloc Allocator) (Slice[T], error) {
+	checkLen("TryCreateSlice()", sliceLen)
+	slice, err := TryAlloc[T](alloc, sliceLen)
+	return sliceFromSlice(slice), err
+}
+
 // Cretes a new `Slice[T]` with specified capacity (length 0), using provided `Allocator`
 func CreateEmptySlice[T any](sliceCap int, alloc Allocator) Slice[T] {
+	checkLen("CreateEmptySlice()", sliceCap)
 	slice := Alloc[T](alloc, sliceCap)
 	return sliceFromSlice(slice[:0])
 }
@@ -122,6 +134,8 @@ var _ ll.SliceLike[byte] = (*Slice[byte])(nil)
 //
 // Analogous to `slice[start:end]`
 func (s Slice[T]) SubSlice(start, end int) SubSlice[T] {
+	checkSubSlice("Slice[T].SubSlice()", start, end, int(s.len))
+	// Always checked, since the Golang runtime only catches an end past the capacity
 	if end > int(s.len) {
 		panic(fmt.Sprintf("fatal: go_manual_memory: Slice[T].SubSlice(): end index %d is greater than len %d", end, s.len))
 	}
@@ -199,6 +213,8 @@ func (ss SubSlice[T]) GetPtr(idx int) *T {
 //
 // Analogous to `slice[start:end]`
 func (ss SubSlice[T]) SubSlice(start, end int) SubSlice[T] {
+	checkSubSlice("SubSlice[T].SubSlice()", start, end, int(ss.len))
+	// Always checked, since the Golang runtime only catches an end past the capacity
 	if end > int(ss.len) {
 		panic(fmt.Sprintf("fatal: go_manual_memory: SubSlice[T].SubSlice(): end index %d is greater than len %d", end, ss.len))
 	}
@@ -0,0 +1,105 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+// Fail the test if `action` does not panic with a message containing `msg`
+func expectPanicMessage(t *testing.T, what string, msg string, action func()) {
+	t.Helper()
+	defer func() {
+		got := fmt.Sprint(recover())
+		if !strings.Contains(got, msg) {
+			t.Errorf("\ntest case failed: panic message for %s\nEXP: %q\nGOT: %q\n", what, msg, got)
+		}
+	}()
+	action()
+}
+
+func TestSliceSubSlice(t *testing.T) {
+	alloc := NewGoAllocator()
+	// Extra capacity past the length, so an end past the length is still inside the memory
+	slice := CreateEmptySlice[int](8, alloc)
+	slice.len = 5
+	for i, val := range []int{0, 1, 2, 3, 4} {
+		*slice.GetPtr(i) = val
+	}
+	sub := slice.SubSlice(1, 4)
+	if sub.Len() != 3 || *sub.GetPtr(0) != 1 || *sub.GetPtr(2) != 3 {
+		t.Errorf("\ntest case failed: SubSlice()\nLEN: %d\n", sub.Len())
+	}
+	subSub := sub.SubSlice(1, 3)
+	if subSub.Len() != 2 || *subSub.GetPtr(0) != 2 {
+		t.Errorf("\ntest case failed: SubSlice() of a SubSlice()\nLEN: %d\n", subSub.Len())
+	}
+	if empty := slice.SubSlice(5, 5); empty.Len() != 0 {
+		t.Errorf("\ntest case failed: empty SubSlice() at the end\nLEN: %d\n", empty.Len())
+	}
+	if whole := slice.WholeSlice(); whole.Len() != 5 {
+		t.Errorf("\ntest case failed: WholeSlice()\nLEN: %d\n", whole.Len())
+	}
+	// An end past the length always panics with a clear message, even without the safety checks
+	expectPanicMessage(t, "Slice[T].SubSlice() end past the length", "end index 6 is greater than len 5", func() { slice.SubSlice(0, 6) })
+	expectPanicMessage(t, "SubSlice[T].SubSlice() end past the length", "end index 4 is greater than len 3", func() { sub.SubSlice(0, 4) })
+	expectPanic(t, "StartSlice() past the length", func() { slice.StartSlice(6) })
+	for _, bounds := range [][2]int{{3, 1}, {-1, 2}} {
+		expectPanic(t, fmt.Sprintf("Slice[T].SubSlice(%d, %d)", bounds[0], bounds[1]), func() {
+			slice.SubSlice(bounds[0], bounds[1])
+		})
+		expectPanic(t, fmt.Sprintf("SubSlice[T].SubSlice(%d, %d)", bounds[0], bounds[1]), func() {
+			sub.SubSlice(bounds[0], bounds[1])
+		})
+	}
+	slice.Destroy(alloc)
+}
+
+func TestSafetyChecks(t *testing.T) {
+	if !SAFETY_CHECKS {
+		t.Skip("safety checks are compiled out with -tags gomm_unchecked")
+	}
+	alloc := NewGoAllocator()
+	slice := CreateSlice[int](5, alloc)
+	expectPanicMessage(t, "SubSlice() with start greater than end", "start index 3 is greater than end index 1", func() { slice.SubSlice(3, 1) })
+	expectPanicMessage(t, "SubSlice() with negative start", "negative start index -1", func() { slice.SubSlice(-1, 2) })
+	expectPanicMessage(t, "CreateSlice() with negative length", "CreateSlice(): negative length -1", func() { CreateSlice[int](-1, alloc) })
+	expectPanicMessage(t, "TryCreateSlice() with negative length", "TryCreateSlice(): negative length -1", func() { TryCreateSlice[int](-1, alloc) })
+	expectPanicMessage(t, "CreateEmptySlice() with negative capacity", "CreateEmptySlice(): negative length -1", func() { CreateEmptySlice[int](-1, alloc) })
+	expectPanicMessage(t, "CreateList() with negative length", "CreateList(): negative length -1", func() { CreateList[int](-1, alloc) })
+	list := slice.ToList(alloc)
+	expectPanicMessage(t, "OffsetLen() shrinking past zero", "cannot shrink len 5 by 6", func() { list.OffsetLen(-6) })
+	if list.Len() != 5 {
+		t.Errorf("\ntest case failed: list changed by a failed shrink\nEXP: 5\nGOT: %d\n", list.Len())
+	}
+	list.OffsetLen(-5)
+	if list.Len() != 0 {
+		t.Errorf("\ntest case failed: shrink to zero\nGOT: %d\n", list.Len())
+	}
+	list.Destroy()
+	expectPanicMessage(t, "checkLen() of a negative length", "negative length", func() { checkLen("test", -1) })
+	checkLen("test", 0)
+	if bits.UintSize == 64 {
+		// Converted at runtime, since the constant would overflow `int` on 32-bit platforms
+		maxLen := uint64(math.MaxUint32)
+		checkLen("test", int(maxLen))
+		expectPanicMessage(t, "checkLen() of a length over uint32", "overflows the max length", func() { checkLen("test", int(maxLen+1)) })
+	}
+}
+
+func TestClampCap(t *testing.T) {
+	if got := clampCap(12); got != 12 {
+		t.Errorf("\ntest case failed: clampCap() of a small capacity\nEXP: 12\nGOT: %d\n", got)
+	}
+	if bits.UintSize == 64 {
+		// Converted at runtime, since the constant would overflow `int` on 32-bit platforms
+		maxCap := uint64(math.MaxUint32)
+		for _, cap := range []uint64{maxCap, maxCap + 1, maxCap * 4} {
+			if got := clampCap(int(cap)); got != math.MaxUint32 {
+				t.Errorf("\ntest case failed: clampCap() past the max capacity\nCAP: %d\nEXP: %d\nGOT: %d\n", cap, maxCap, got)
+			}
+		}
+	}
+}
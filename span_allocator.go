@@ -1,112 +1,349 @@
 package go_manual_memory
 
-// import (
-// 	"math/bits"
-// 	"slices"
-// 	"unsafe"
-
-// 	esort "github.com/gabe-lee/go_effect_sort"
-// )
-
-// const MIN_CHUNK_SIZE = PAGE_SIZE
-
-// type SpanAllocator struct {
-// 	chunks                     [][]byte
-// 	free_spans                 []span
-// 	free_spans_sorted_by_len   []uint32
-// 	free_spans_sorted_by_start []uint32
-// }
-
-// func spanEqualLen(slice []span, idx int, val span) bool {
-// 	return slice[idx].len == val.len
-// }
-// func spanGreaterLen(slice []span, idx int, val span) bool {
-// 	return slice[idx].len > val.len
-// }
-
-// func (s *SpanAllocator) addNewSpan(ss span) {
-// }
-
-// func (s *SpanAllocator) findFreeSpan(span_len, align uintptr) (sp span, found bool) {
-// 	const _SPAN_BEFORE uint8 = 0b01
-// 	const _SPAN_AFTER uint8 = 0b10
-// 	const _SPAN_BEFORE_AND_AFTER uint8 = 0b11
-// 	const _SPAN_EXACT uint8 = 0b00
-// 	span_after := span{len: uint32(span_len)}
-// 	free_span_idx := esort.BinaryInsertIndex(s.free_spans, span_after, spanEqualLen, spanGreaterLen)
-// 	if free_span_idx >= len(s.free_spans) {
-// 		return span_after, false
-// 	}
-// 	var has_span_before uint8 = 0b00
-// 	var has_span_after uint8 = 0b00
-// 	var span_before span
-// 	free_span := s.free_spans[free_span_idx]
-// 	free_span_addr, free_span_align := s.spanAddrAlign(free_span)
-// 	free_span_original_len := free_span.len
-// 	for free_span_align < align {
-// 		next_aligned := (free_span_addr + align - 1) & ^(align - 1)
-// 		if next_aligned+span_len > free_span_addr+uintptr(free_span.len) {
-// 			free_span_idx += 1
-// 			if free_span_idx >= len(s.free_spans) {
-// 				return span_after, false
-// 			}
-// 			free_span := s.free_spans[free_span_idx]
-// 			free_span_addr, free_span_align = s.spanAddrAlign(free_span)
-// 			free_span_original_len = free_span.len
-// 		} else {
-// 			delta := uint32(next_aligned - free_span_addr)
-// 			span_before = span{
-// 				chunk: free_span.chunk,
-// 				start: free_span.start,
-// 				len:   delta,
-// 			}
-// 			has_span_before = 0b01
-// 			free_span.start += delta
-// 			free_span.len -= delta
-// 		}
-// 	}
-// 	leftover := free_span.len - span_after.len
-// 	free_span.len = span_after.len
-// 	span_after.len = leftover
-// 	if span_after.len > 0 {
-// 		span_after.chunk = free_span.chunk
-// 		span_after.start = free_span.start + free_span.len
-// 		has_span_after = 0b10
-// 	}
-// 	var alter_mode uint8 = has_span_before | has_span_after
-// 	switch alter_mode {
-// 	case _SPAN_EXACT:
-// 		slices.Delete(s.free_spans, free_span_idx, free_span_idx+1)
-// 		esort.BinarySearch()
-// 	}
-// }
-
-// // RawAlloc implements Allocator.
-// func (s *SpanAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
-// 	panic("unimplemented")
-// }
-
-// // RawFree implements Allocator.
-// func (s *SpanAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
-// 	panic("unimplemented")
-// }
-
-// // RawResize implements Allocator.
-// func (s *SpanAllocator) RawResize(ptr unsafe.Pointer, old_len uintptr, new_len uintptr, align uintptr) (new_ptr unsafe.Pointer, alloc_len uintptr) {
-// 	panic("unimplemented")
-// }
-
-// var _ Allocator = (*SpanAllocator)(nil)
-
-// type span struct {
-// 	chunk uint32
-// 	start uint32
-// 	len   uint32
-// }
-
-// func (s *SpanAllocator) spanAddrAlign(ss span) (addr, align uintptr) {
-// 	cdata := s.chunks[ss.chunk]
-// 	caddr := uintptr(unsafe.Pointer(unsafe.SliceData(cdata)))
-// 	saddr := caddr + uintptr(ss.start)
-// 	return saddr, uintptr(bits.TrailingZeros(uint(saddr)))
-// }
+import (
+	"fmt"
+	"math"
+	"slices"
+	"unsafe"
+
+	esort "github.com/gabe-lee/go_effect_sort"
+	ll "github.com/gabe-lee/go_list_like"
+)
+
+// The smallest chunk of memory the `SpanAllocator` will request from its parent
+const MIN_CHUNK_SIZE = PAGE_SIZE
+
+// The largest chunk of memory the `SpanAllocator` can manage (span offsets are 32-bit)
+const MAX_CHUNK_SIZE = math.MaxUint32 & ^uintptr(PAGE_SIZE-1)
+
+// Every span handed out by the `SpanAllocator` is a multiple of this size
+// and aligned to at least this size
+const MIN_SPAN_SIZE = 16
+
+// This allocator requests large chunks of memory (at least `MIN_CHUNK_SIZE`) from a parent
+// allocator and carves them into spans, keeping track of free spans both by length (for
+// best-fit allocation) and by start (for coalescing neighbors when freed)
+//
+// Memory is never returned to the parent allocator until `Destroy()` is called, making
+// this allocator well suited for long-lived buffers that would otherwise fragment the parent
+type SpanAllocator struct {
+	parentAlloc                Allocator
+	fixedChunkLen              uintptr
+	maxChunkCount              uint32
+	chunks                     [][]byte
+	chunks_sorted_by_addr      []uint32
+	free_spans_sorted_by_len   []span
+	free_spans_sorted_by_start []span
+	byLenAdapter               ll.SliceAdapter[span]
+	byStartAdapter             ll.SliceAdapter[span]
+}
+
+type span struct {
+	chunk uint32
+	start uint32
+	len   uint32
+}
+
+func NewSpanAllocator(parent Allocator) *SpanAllocator {
+	return newSpanAllocator(parent, 0, math.MaxUint32)
+}
+
+// Create a `SpanAllocator` that always requests chunks of exactly `fixedChunkLen` bytes
+// (or a variable size if 0), and never holds more than `maxChunkCount` chunks
+func newSpanAllocator(parent Allocator, fixedChunkLen uintptr, maxChunkCount uint32) *SpanAllocator {
+	s := SpanAllocator{
+		parentAlloc:                parent,
+		fixedChunkLen:              fixedChunkLen,
+		maxChunkCount:              maxChunkCount,
+		chunks:                     make([][]byte, 0),
+		chunks_sorted_by_addr:      make([]uint32, 0),
+		free_spans_sorted_by_len:   make([]span, 0),
+		free_spans_sorted_by_start: make([]span, 0),
+	}
+	s.byLenAdapter = ll.NewSliceAdapter(&s.free_spans_sorted_by_len)
+	s.byStartAdapter = ll.NewSliceAdapter(&s.free_spans_sorted_by_start)
+	return &s
+}
+
+func spanEqual(slice ll.SliceLike[span], idx int, val span) bool {
+	return ll.Get(slice, idx) == val
+}
+func spanGreaterLen(slice ll.SliceLike[span], idx int, val span) bool {
+	sp := ll.Get(slice, idx)
+	if sp.len != val.len {
+		return sp.len > val.len
+	}
+	if sp.chunk != val.chunk {
+		return sp.chunk > val.chunk
+	}
+	return sp.start > val.start
+}
+func spanEqualStart(slice ll.SliceLike[span], idx int, val span) bool {
+	sp := ll.Get(slice, idx)
+	return sp.chunk == val.chunk && sp.start == val.start
+}
+func spanGreaterStart(slice ll.SliceLike[span], idx int, val span) bool {
+	sp := ll.Get(slice, idx)
+	if sp.chunk != val.chunk {
+		return sp.chunk > val.chunk
+	}
+	return sp.start > val.start
+}
+
+func (s *SpanAllocator) chunkAddr(chunk uint32) uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(s.chunks[chunk])))
+}
+
+func (s *SpanAllocator) spanAddr(sp span) uintptr {
+	return s.chunkAddr(sp.chunk) + uintptr(sp.start)
+}
+
+func (s *SpanAllocator) spanPtr(sp span) unsafe.Pointer {
+	return unsafe.Add(unsafe.Pointer(unsafe.SliceData(s.chunks[sp.chunk])), sp.start)
+}
+
+// Find the chunk that contains `ptr`, if any
+func (s *SpanAllocator) chunkOf(ptr unsafe.Pointer) (chunk uint32, found bool) {
+	addr := uintptr(ptr)
+	lo, hi := 0, len(s.chunks_sorted_by_addr)
+	for lo < hi {
+		mid := lo + ((hi - lo) >> 1)
+		if s.chunkAddr(s.chunks_sorted_by_addr[mid]) > addr {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if lo == 0 {
+		return 0, false
+	}
+	chunk = s.chunks_sorted_by_addr[lo-1]
+	if addr >= s.chunkAddr(chunk)+uintptr(len(s.chunks[chunk])) {
+		return 0, false
+	}
+	return chunk, true
+}
+
+func (s *SpanAllocator) addFreeSpan(sp span) {
+	esort.Sorted_Insert(s.byLenAdapter, sp, spanEqual, spanGreaterLen, esort.MoveNoSideEffect)
+	esort.Sorted_Insert(s.byStartAdapter, sp, spanEqualStart, spanGreaterStart, esort.MoveNoSideEffect)
+}
+
+func (s *SpanAllocator) removeFreeSpan(sp span) {
+	idx, found := esort.Sorted_Search(s.byLenAdapter, sp, spanEqual, spanGreaterLen)
+	if found {
+		s.free_spans_sorted_by_len = slices.Delete(s.free_spans_sorted_by_len, idx, idx+1)
+	}
+	idx, found = esort.Sorted_Search(s.byStartAdapter, sp, spanEqualStart, spanGreaterStart)
+	if found {
+		s.free_spans_sorted_by_start = slices.Delete(s.free_spans_sorted_by_start, idx, idx+1)
+	}
+}
+
+// Return a span to the free lists, merging it with any free neighbors in the same chunk
+func (s *SpanAllocator) releaseSpan(sp span) {
+	idx := esort.Sorted_InsertIndex(s.byStartAdapter, sp, spanEqualStart, spanGreaterStart)
+	if idx > 0 {
+		prev := s.free_spans_sorted_by_start[idx-1]
+		if prev.chunk == sp.chunk && prev.start+prev.len == sp.start {
+			s.removeFreeSpan(prev)
+			sp.start = prev.start
+			sp.len += prev.len
+		}
+	}
+	nextKey := span{chunk: sp.chunk, start: sp.start + sp.len}
+	idx, found := esort.Sorted_Search(s.byStartAdapter, nextKey, spanEqualStart, spanGreaterStart)
+	if found {
+		next := s.free_spans_sorted_by_start[idx]
+		s.removeFreeSpan(next)
+		sp.len += next.len
+	}
+	s.addFreeSpan(sp)
+}
+
+// Find the smallest free span that can hold `span_len` bytes at the requested alignment,
+// splitting off any unused space before and after it back into the free lists
+func (s *SpanAllocator) findFreeSpan(span_len, align uintptr) (sp span, found bool) {
+	probe := span{len: uint32(span_len)}
+	idx := esort.Sorted_InsertIndex(s.byLenAdapter, probe, spanEqual, spanGreaterLen)
+	for ; idx < len(s.free_spans_sorted_by_len); idx += 1 {
+		free_span := s.free_spans_sorted_by_len[idx]
+		addr := s.spanAddr(free_span)
+		delta := alignForward(addr, align) - addr
+		if delta+span_len > uintptr(free_span.len) {
+			continue
+		}
+		s.removeFreeSpan(free_span)
+		if delta > 0 {
+			s.addFreeSpan(span{
+				chunk: free_span.chunk,
+				start: free_span.start,
+				len:   uint32(delta),
+			})
+		}
+		sp = span{
+			chunk: free_span.chunk,
+			start: free_span.start + uint32(delta),
+			len:   uint32(span_len),
+		}
+		leftover := free_span.len - uint32(delta) - sp.len
+		if leftover > 0 {
+			s.addFreeSpan(span{
+				chunk: free_span.chunk,
+				start: sp.start + sp.len,
+				len:   leftover,
+			})
+		}
+		return sp, true
+	}
+	return sp, false
+}
+
+// Request a new chunk from the parent allocator large enough to hold
+// `span_len` bytes at the requested alignment, and add it as a single free span
+func (s *SpanAllocator) addNewChunk(span_len, align uintptr) error {
+	if uint32(len(s.chunks)) >= s.maxChunkCount {
+		return fmt.Errorf("%w: SpanAllocator.addNewChunk(): chunk count would exceed max chunk count %d", ErrOutOfMemory, s.maxChunkCount)
+	}
+	chunk_len := alignForward(max(span_len, MIN_CHUNK_SIZE), PAGE_SIZE)
+	max_len := MAX_CHUNK_SIZE
+	if s.fixedChunkLen != 0 {
+		chunk_len = s.fixedChunkLen
+		max_len = s.fixedChunkLen
+	}
+	ptr, alloc_len, err := TryRawAlloc(s.parentAlloc, chunk_len, align)
+	if err != nil {
+		return err
+	}
+	alloc_len = min(alloc_len, max_len) & ^uintptr(MIN_SPAN_SIZE-1)
+	id := uint32(len(s.chunks))
+	s.chunks = append(s.chunks, unsafe.Slice((*byte)(ptr), alloc_len))
+	addr := uintptr(ptr)
+	idx, _ := slices.BinarySearchFunc(s.chunks_sorted_by_addr, addr, func(chunk uint32, addr uintptr) int {
+		chunkAddr := s.chunkAddr(chunk)
+		if chunkAddr < addr {
+			return -1
+		}
+		if chunkAddr > addr {
+			return 1
+		}
+		return 0
+	})
+	s.chunks_sorted_by_addr = slices.Insert(s.chunks_sorted_by_addr, idx, id)
+	s.addFreeSpan(span{chunk: id, start: 0, len: uint32(alloc_len)})
+	return nil
+}
+
+// RawAlloc implements Allocator.
+func (s *SpanAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := s.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (s *SpanAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	align = max(align, MIN_SPAN_SIZE)
+	span_len := alignForward(len, MIN_SPAN_SIZE)
+	max_len := MAX_CHUNK_SIZE
+	if s.fixedChunkLen != 0 {
+		max_len = s.fixedChunkLen
+	}
+	if span_len > max_len-min(align, max_len) {
+		return nil, 0, fmt.Errorf("%w: SpanAllocator.TryRawAlloc(): requested len %d (align %d) is greater than max chunk size %d", ErrOutOfMemory, len, align, max_len)
+	}
+	sp, found := s.findFreeSpan(span_len, align)
+	if !found {
+		if err = s.addNewChunk(span_len, align); err != nil {
+			return nil, 0, err
+		}
+		if sp, found = s.findFreeSpan(span_len, align); !found {
+			return nil, 0, fmt.Errorf("%w: SpanAllocator.TryRawAlloc(): new chunk from parent cannot hold requested len %d (align %d)", ErrOutOfMemory, len, align)
+		}
+	}
+	return s.spanPtr(sp), uintptr(sp.len), nil
+}
+
+// RawFree implements Allocator.
+func (s *SpanAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	chunk, found := s.chunkOf(ptr)
+	if !found {
+		return
+	}
+	s.releaseSpan(span{
+		chunk: chunk,
+		start: uint32(uintptr(ptr) - s.chunkAddr(chunk)),
+		len:   uint32(alignForward(len, MIN_SPAN_SIZE)),
+	})
+}
+
+// RawResizeInPlace implements Allocator.
+func (s *SpanAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	old_span_len := alignForward(old_len, MIN_SPAN_SIZE)
+	new_span_len := alignForward(new_len, MIN_SPAN_SIZE)
+	if new_span_len == old_span_len {
+		return ptr, true
+	}
+	chunk, found := s.chunkOf(ptr)
+	if !found {
+		return ptr, false
+	}
+	start := uint32(uintptr(ptr) - s.chunkAddr(chunk))
+	if new_span_len < old_span_len {
+		s.releaseSpan(span{
+			chunk: chunk,
+			start: start + uint32(new_span_len),
+			len:   uint32(old_span_len - new_span_len),
+		})
+		return ptr, true
+	}
+	need := uint32(new_span_len - old_span_len)
+	nextKey := span{chunk: chunk, start: start + uint32(old_span_len)}
+	idx, found := esort.Sorted_Search(s.byStartAdapter, nextKey, spanEqualStart, spanGreaterStart)
+	if !found {
+		return ptr, false
+	}
+	next := s.free_spans_sorted_by_start[idx]
+	if next.len < need {
+		return ptr, false
+	}
+	s.removeFreeSpan(next)
+	if next.len > need {
+		s.addFreeSpan(span{
+			chunk: chunk,
+			start: next.start + need,
+			len:   next.len - need,
+		})
+	}
+	return ptr, true
+}
+
+// Owns implements Owner.
+func (s *SpanAllocator) Owns(ptr unsafe.Pointer) bool {
+	_, found := s.chunkOf(ptr)
+	return found
+}
+
+// Return all chunks to the parent allocator
+//
+// All memory allocated from this allocator becomes invalid
+func (s *SpanAllocator) Destroy() {
+	for _, chunk := range s.chunks {
+		s.parentAlloc.RawFree(unsafe.Pointer(unsafe.SliceData(chunk)), uintptr(len(chunk)))
+	}
+	s.chunks = s.chunks[:0]
+	s.chunks_sorted_by_addr = s.chunks_sorted_by_addr[:0]
+	s.free_spans_sorted_by_len = s.free_spans_sorted_by_len[:0]
+	s.free_spans_sorted_by_start = s.free_spans_sorted_by_start[:0]
+}
+
+var _ FallibleAllocator = (*SpanAllocator)(nil)
+var _ Owner = (*SpanAllocator)(nil)
@@ -0,0 +1,115 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+// Hands out memory `MIN_SPAN_SIZE` bytes past the alignment that was asked for
+type misalignedAllocator struct {
+	parentAlloc Allocator
+}
+
+func (m misalignedAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, _ = m.parentAlloc.RawAlloc(len+MIN_SPAN_SIZE, align)
+	return unsafe.Add(ptr, MIN_SPAN_SIZE), len
+}
+
+func (m misalignedAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	m.parentAlloc.RawFree(unsafe.Add(ptr, -MIN_SPAN_SIZE), len+MIN_SPAN_SIZE)
+}
+
+func (m misalignedAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	return ptr, false
+}
+
+// Check that the free spans of `s` are sorted, never overlap, and that no two
+// neighbouring free spans were left unmerged
+func checkSpanFreeLists(t *testing.T, s *SpanAllocator) {
+	t.Helper()
+	if len(s.free_spans_sorted_by_len) != len(s.free_spans_sorted_by_start) {
+		t.Fatalf("\ntest case failed: free list len mismatch\nBY LEN: %d\nBY START: %d\n", len(s.free_spans_sorted_by_len), len(s.free_spans_sorted_by_start))
+	}
+	for i := 1; i < len(s.free_spans_sorted_by_start); i += 1 {
+		prev := s.free_spans_sorted_by_start[i-1]
+		next := s.free_spans_sorted_by_start[i]
+		if prev.chunk == next.chunk && prev.start+prev.len >= next.start {
+			t.Fatalf("\ntest case failed: free spans overlap or were not merged\nPREV: %+v\nNEXT: %+v\n", prev, next)
+		}
+	}
+	for i := 1; i < len(s.free_spans_sorted_by_len); i += 1 {
+		if s.free_spans_sorted_by_len[i-1].len > s.free_spans_sorted_by_len[i].len {
+			t.Fatalf("\ntest case failed: free spans not sorted by len\nIDX: %d\n", i)
+		}
+	}
+}
+
+func TestSpanAllocator(t *testing.T) {
+	s := NewSpanAllocator(NewGoAllocator())
+	live := stressAllocator(t, s, 1, 5000, PAGE_SIZE*2, 256)
+	checkSpanFreeLists(t, s)
+	for _, block := range live {
+		if !s.Owns(block.ptr) {
+			t.Fatalf("\ntest case failed: live block not owned\nBLOCK: 0x%x\n", uintptr(block.ptr))
+		}
+	}
+	freeTestBlocks(s, live)
+	checkSpanFreeLists(t, s)
+	if len(s.free_spans_sorted_by_start) != len(s.chunks) {
+		t.Errorf("\ntest case failed: chunks not fully coalesced after freeing everything\nEXP SPANS: %d\nGOT SPANS: %d\n", len(s.chunks), len(s.free_spans_sorted_by_start))
+	}
+	for _, sp := range s.free_spans_sorted_by_start {
+		if sp.start != 0 || uintptr(sp.len) != uintptr(len(s.chunks[sp.chunk]))&^(MIN_SPAN_SIZE-1) {
+			t.Errorf("\ntest case failed: free span does not cover its whole chunk\nSPAN: %+v\nCHUNK LEN: %d\n", sp, len(s.chunks[sp.chunk]))
+		}
+	}
+	s.Destroy()
+}
+
+func TestSpanAllocatorResizeInPlace(t *testing.T) {
+	s := NewSpanAllocator(NewGoAllocator())
+	a, _ := s.RawAlloc(64, 16)
+	b, _ := s.RawAlloc(64, 16)
+	if _, ok := s.RawResizeInPlace(a, 64, 128); ok {
+		t.Errorf("\ntest case failed: grew into an allocated neighbour\n")
+	}
+	if _, ok := s.RawResizeInPlace(b, 64, 1024); !ok {
+		t.Errorf("\ntest case failed: could not grow into free space\n")
+	}
+	if _, ok := s.RawResizeInPlace(b, 1024, 32); !ok {
+		t.Errorf("\ntest case failed: could not shrink\n")
+	}
+	c, _ := s.RawAlloc(64, 16)
+	if uintptr(c) != uintptr(b)+32 {
+		t.Errorf("\ntest case failed: shrunk space was not reused\nEXP: 0x%x\nGOT: 0x%x\n", uintptr(b)+32, uintptr(c))
+	}
+	checkSpanFreeLists(t, s)
+	if s.Owns(unsafe.Pointer(&s)) {
+		t.Errorf("\ntest case failed: owns a pointer it never allocated\n")
+	}
+	s.Destroy()
+}
+
+func TestSpanAllocatorMaxChunk(t *testing.T) {
+	s := newSpanAllocator(NewGoAllocator(), PAGE_SIZE, 1)
+	if _, _, err := s.TryRawAlloc(PAGE_SIZE+1, 16); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: oversize request\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if _, _, err := s.TryRawAlloc(PAGE_SIZE/2, 16); err != nil {
+		t.Fatalf("\ntest case failed: first chunk\nGOT ERR: %v\n", err)
+	}
+	if _, _, err := s.TryRawAlloc(PAGE_SIZE, 16); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: max chunk count\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	s.Destroy()
+}
+
+func TestSpanAllocatorMisalignedParent(t *testing.T) {
+	s := NewSpanAllocator(misalignedAllocator{NewGoAllocator()})
+	ptr, _, err := s.TryRawAlloc(PAGE_SIZE, PAGE_SIZE)
+	if !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: chunk that cannot hold the span\nEXP ERR: %v\nGOT ERR: %v\nGOT PTR: 0x%x\n", ErrOutOfMemory, err, uintptr(ptr))
+	}
+	s.Destroy()
+}
@@ -0,0 +1,184 @@
+package go_manual_memory
+
+import (
+	"expvar"
+	"math/bits"
+	"sync/atomic"
+	"unsafe"
+)
+
+// The number of power-of-2 buckets in the size histogram of `AllocatorStats`
+//
+// Bucket `i` counts allocations with a length in the range (`1 << (i-1)`, `1 << i`]
+const STATS_HISTOGRAM_BUCKETS = 64
+
+// A point-in-time snapshot of the counters kept by a `StatsAllocator`
+type AllocatorStats struct {
+	BytesInUse                uint64
+	PeakBytesInUse            uint64
+	AllocCount                uint64
+	AllocFailCount            uint64
+	FreeCount                 uint64
+	ResizeCount               uint64
+	ResizeInPlaceSuccessCount uint64
+	SizeHistogram             [STATS_HISTOGRAM_BUCKETS]uint64
+}
+
+// Return the number of allocations that have not yet been freed
+func (s AllocatorStats) LiveCount() uint64 {
+	return s.AllocCount - s.FreeCount
+}
+
+// Return the fraction (0.0 to 1.0) of resize requests that succeeded in place
+func (s AllocatorStats) ResizeInPlaceSuccessRate() float64 {
+	if s.ResizeCount == 0 {
+		return 0
+	}
+	return float64(s.ResizeInPlaceSuccessCount) / float64(s.ResizeCount)
+}
+
+// This allocator wraps any other `Allocator` and keeps counters of how it is used,
+// which can be read at any time with `Stats()` or exported with `Publish()`
+//
+// The returned `alloc_len` is always exactly the requested length, so that the
+// number of bytes in use stays exact when memory is freed
+//
+// The counters are safe to read concurrently, but the `StatsAllocator` itself is only
+// safe for concurrent use if the wrapped allocator is
+type StatsAllocator struct {
+	parentAlloc               Allocator
+	bytesInUse                atomic.Uint64
+	peakBytesInUse            atomic.Uint64
+	allocCount                atomic.Uint64
+	allocFailCount            atomic.Uint64
+	freeCount                 atomic.Uint64
+	resizeCount               atomic.Uint64
+	resizeInPlaceSuccessCount atomic.Uint64
+	sizeHistogram             [STATS_HISTOGRAM_BUCKETS]atomic.Uint64
+}
+
+// Create a new `StatsAllocator` that counts all allocations made through the parent allocator
+func NewStatsAllocator(parent Allocator) *StatsAllocator {
+	return &StatsAllocator{
+		parentAlloc: parent,
+	}
+}
+
+func statsSizeBucket(len uintptr) int {
+	if len == 0 {
+		return 0
+	}
+	return min(bits.Len(uint(len-1)), STATS_HISTOGRAM_BUCKETS-1)
+}
+
+func (s *StatsAllocator) addBytesInUse(delta uint64) {
+	inUse := s.bytesInUse.Add(delta)
+	for {
+		peak := s.peakBytesInUse.Load()
+		if inUse <= peak || s.peakBytesInUse.CompareAndSwap(peak, inUse) {
+			return
+		}
+	}
+}
+
+func (s *StatsAllocator) countAlloc(len uintptr) {
+	s.allocCount.Add(1)
+	s.sizeHistogram[statsSizeBucket(len)].Add(1)
+	s.addBytesInUse(uint64(len))
+}
+
+// RawAlloc implements Allocator.
+func (s *StatsAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, _ = s.parentAlloc.RawAlloc(len, align)
+	// A nil pointer is never freed, so counting it would leave its bytes in use forever
+	if ptr == nil {
+		if len != 0 {
+			s.allocFailCount.Add(1)
+		}
+		return nil, 0
+	}
+	s.countAlloc(len)
+	return ptr, len
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (s *StatsAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	ptr, _, err = TryRawAlloc(s.parentAlloc, len, align)
+	if err != nil {
+		s.allocFailCount.Add(1)
+		return nil, 0, err
+	}
+	if ptr == nil {
+		return nil, 0, nil
+	}
+	s.countAlloc(len)
+	return ptr, len, nil
+}
+
+// RawFree implements Allocator.
+func (s *StatsAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil {
+		return
+	}
+	s.parentAlloc.RawFree(ptr, len)
+	s.freeCount.Add(1)
+	s.bytesInUse.Add(-uint64(len))
+}
+
+// RawResizeInPlace implements Allocator.
+func (s *StatsAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	newPtr, success = s.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	s.resizeCount.Add(1)
+	if !success {
+		return
+	}
+	s.resizeInPlaceSuccessCount.Add(1)
+	if new_len > old_len {
+		s.addBytesInUse(uint64(new_len - old_len))
+	} else {
+		s.bytesInUse.Add(-uint64(old_len - new_len))
+	}
+	return
+}
+
+// Return a snapshot of all counters
+//
+// Each counter is read atomically, but the snapshot as a whole is not
+func (s *StatsAllocator) Stats() AllocatorStats {
+	stats := AllocatorStats{
+		BytesInUse:                s.bytesInUse.Load(),
+		PeakBytesInUse:            s.peakBytesInUse.Load(),
+		AllocCount:                s.allocCount.Load(),
+		AllocFailCount:            s.allocFailCount.Load(),
+		FreeCount:                 s.freeCount.Load(),
+		ResizeCount:               s.resizeCount.Load(),
+		ResizeInPlaceSuccessCount: s.resizeInPlaceSuccessCount.Load(),
+	}
+	for i := range s.sizeHistogram {
+		stats.SizeHistogram[i] = s.sizeHistogram[i].Load()
+	}
+	return stats
+}
+
+// Reset the peak bytes in use to the current bytes in use
+func (s *StatsAllocator) ResetPeak() {
+	s.peakBytesInUse.Store(s.bytesInUse.Load())
+}
+
+// Publish a snapshot of the counters with `expvar` under the given name,
+// so they are served as JSON from `/debug/vars`
+//
+// Like `expvar.Publish()`, this panics if the name is already in use
+func (s *StatsAllocator) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return s.Stats()
+	}))
+}
+
+// Owns implements Owner.
+func (s *StatsAllocator) Owns(ptr unsafe.Pointer) bool {
+	return Owns(s.parentAlloc, ptr)
+}
+
+var _ FallibleAllocator = (*StatsAllocator)(nil)
+var _ Owner = (*StatsAllocator)(nil)
@@ -0,0 +1,120 @@
+package go_manual_memory
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"math/bits"
+	"testing"
+)
+
+func TestStatsAllocator(t *testing.T) {
+	s := NewStatsAllocator(NewArenaAllocator(NewGoAllocator(), PAGE_SIZE))
+	a, _ := s.RawAlloc(100, 8)
+	b, b_len := s.RawAlloc(1, 1)
+	if b_len != 1 {
+		t.Errorf("\ntest case failed: alloc_len\nEXP: 1\nGOT: %d\n", b_len)
+	}
+	s.RawFree(a, 100)
+	if _, ok := s.RawResizeInPlace(b, 1, 300); !ok {
+		t.Fatalf("\ntest case failed: resize of last arena allocation\n")
+	}
+	if _, ok := s.RawResizeInPlace(a, 100, 200); ok {
+		t.Fatalf("\ntest case failed: resize of freed arena allocation\n")
+	}
+	s.ResetPeak()
+	if _, ok := s.RawResizeInPlace(b, 300, 10); !ok {
+		t.Fatalf("\ntest case failed: shrink of last arena allocation\n")
+	}
+	stats := s.Stats()
+	exp := AllocatorStats{
+		BytesInUse:                10,
+		PeakBytesInUse:            300,
+		AllocCount:                2,
+		FreeCount:                 1,
+		ResizeCount:               3,
+		ResizeInPlaceSuccessCount: 2,
+	}
+	exp.SizeHistogram[0] = 1
+	exp.SizeHistogram[7] = 1
+	if stats != exp {
+		t.Errorf("\ntest case failed: stats\nEXP: %+v\nGOT: %+v\n", exp, stats)
+	}
+	if stats.LiveCount() != 1 {
+		t.Errorf("\ntest case failed: live count\nEXP: 1\nGOT: %d\n", stats.LiveCount())
+	}
+	if rate := stats.ResizeInPlaceSuccessRate(); rate != 2.0/3.0 {
+		t.Errorf("\ntest case failed: resize success rate\nEXP: %v\nGOT: %v\n", 2.0/3.0, rate)
+	}
+	if rate := (AllocatorStats{}).ResizeInPlaceSuccessRate(); rate != 0 {
+		t.Errorf("\ntest case failed: resize success rate with no resizes\nEXP: 0\nGOT: %v\n", rate)
+	}
+}
+
+func TestStatsAllocatorSizeBuckets(t *testing.T) {
+	tests := []struct {
+		len    uintptr
+		bucket int
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 1},
+		{3, 2},
+		{4, 2},
+		{5, 3},
+		{4096, 12},
+		{4097, 13},
+		{^uintptr(0), min(bits.UintSize, STATS_HISTOGRAM_BUCKETS-1)},
+	}
+	for _, test := range tests {
+		if bucket := statsSizeBucket(test.len); bucket != test.bucket {
+			t.Errorf("\ntest case failed: size bucket\nLEN: %d\nEXP: %d\nGOT: %d\n", test.len, test.bucket, bucket)
+		}
+	}
+}
+
+func TestStatsAllocatorFailure(t *testing.T) {
+	s := NewStatsAllocator(failingAllocator{NewGoAllocator(), 64})
+	if _, _, err := s.TryRawAlloc(65, 8); !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("\ntest case failed: oversized request\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	stats := s.Stats()
+	if stats.AllocFailCount != 1 || stats.AllocCount != 0 || stats.BytesInUse != 0 {
+		t.Errorf("\ntest case failed: stats after failure\nGOT: %+v\n", stats)
+	}
+	s = NewStatsAllocator(nilAllocator{})
+	if ptr, alloc_len := s.RawAlloc(64, 8); ptr != nil || alloc_len != 0 {
+		t.Errorf("\ntest case failed: nil from the parent\nGOT LEN: %d\n", alloc_len)
+	}
+	s.RawAlloc(0, 8)
+	if _, _, err := s.TryRawAlloc(0, 8); err != nil {
+		t.Errorf("\ntest case failed: zero length request\nGOT ERR: %v\n", err)
+	}
+	stats = s.Stats()
+	if stats.AllocFailCount != 1 || stats.AllocCount != 0 || stats.BytesInUse != 0 || stats.PeakBytesInUse != 0 {
+		t.Errorf("\ntest case failed: stats after nil allocations\nGOT: %+v\n", stats)
+	}
+}
+
+func TestStatsAllocatorConcurrent(t *testing.T) {
+	s := NewStatsAllocator(NewGoAllocator())
+	stressAllocatorConcurrent(t, s, 8, 512, 64)
+	stats := s.Stats()
+	if stats.BytesInUse != 0 || stats.LiveCount() != 0 || stats.PeakBytesInUse == 0 {
+		t.Errorf("\ntest case failed: stats after freeing everything\nGOT: %+v\n", stats)
+	}
+}
+
+func TestStatsAllocatorPublish(t *testing.T) {
+	s := NewStatsAllocator(NewGoAllocator())
+	Free(s, Alloc[byte](s, 64))
+	s.Publish("go_manual_memory_test_stats")
+	var got AllocatorStats
+	if err := json.Unmarshal([]byte(expvar.Get("go_manual_memory_test_stats").String()), &got); err != nil {
+		t.Fatalf("\ntest case failed: published stats are not valid JSON\nGOT ERR: %v\n", err)
+	}
+	if got != s.Stats() {
+		t.Errorf("\ntest case failed: published stats\nEXP: %+v\nGOT: %+v\n", s.Stats(), got)
+	}
+	expectPanic(t, "publishing the same name twice", func() { s.Publish("go_manual_memory_test_stats") })
+}
@@ -0,0 +1,70 @@
+package go_manual_memory
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// This allocator wraps any other `Allocator` and serializes access to it with a mutex,
+// making it safe for concurrent use by multiple goroutines
+//
+// The wrapped allocator MUST NOT be used directly while it is wrapped
+type SyncAllocator struct {
+	mu    sync.Mutex
+	alloc Allocator
+}
+
+// Create a new `SyncAllocator` that serializes all access to `alloc`
+func NewSyncAllocator(alloc Allocator) *SyncAllocator {
+	return &SyncAllocator{
+		alloc: alloc,
+	}
+}
+
+// Run `action` with exclusive access to the wrapped allocator
+//
+// This can be used to call methods on the wrapped allocator that are
+// not part of the `Allocator` interface, such as `Reset()` or `Destroy()`
+func (s *SyncAllocator) Do(action func(alloc Allocator)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	action(s.alloc)
+}
+
+// RawAlloc implements Allocator.
+func (s *SyncAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.alloc.RawAlloc(len, align)
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (s *SyncAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return TryRawAlloc(s.alloc, len, align)
+}
+
+// RawFree implements Allocator.
+func (s *SyncAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alloc.RawFree(ptr, len)
+}
+
+// RawResizeInPlace implements Allocator.
+func (s *SyncAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.alloc.RawResizeInPlace(ptr, old_len, new_len)
+}
+
+// Owns implements Owner.
+func (s *SyncAllocator) Owns(ptr unsafe.Pointer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Owns(s.alloc, ptr)
+}
+
+var _ FallibleAllocator = (*SyncAllocator)(nil)
+var _ Owner = (*SyncAllocator)(nil)
@@ -0,0 +1,62 @@
+package go_manual_memory
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestSyncAllocatorConcurrent(t *testing.T) {
+	spans := NewSpanAllocator(NewGoAllocator())
+	s := NewSyncAllocator(spans)
+	stressAllocatorConcurrent(t, s, 8, 1024, 64)
+	s.Do(func(alloc Allocator) {
+		if alloc != spans {
+			t.Errorf("\ntest case failed: Do() did not pass the wrapped allocator\n")
+		}
+		checkSpanFreeLists(t, spans)
+		spans.Destroy()
+	})
+}
+
+func TestSyncAllocatorConcurrentPool(t *testing.T) {
+	pool := NewPoolAllocator[[4]uint64](NewGoAllocator())
+	s := NewSyncAllocator(pool)
+	stressAllocatorConcurrent(t, s, 8, pool.SlotSize(), 8)
+	s.Do(func(alloc Allocator) {
+		pool.Release()
+	})
+}
+
+func TestGoAllocatorConcurrent(t *testing.T) {
+	g := NewGoAllocator()
+	stressAllocatorConcurrent(t, g, 8, 1024, 64)
+	if g.LiveCount() != 0 || g.HeldBytes() != 0 {
+		t.Errorf("\ntest case failed: memory held after every worker freed its blocks\nLIVE COUNT: %d\nHELD BYTES: %d\n", g.LiveCount(), g.HeldBytes())
+	}
+}
+
+func TestSyncAllocatorDo(t *testing.T) {
+	s := NewSyncAllocator(NewArenaAllocator(NewGoAllocator(), 0))
+	var wg sync.WaitGroup
+	counter := 0
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				s.Do(func(alloc Allocator) {
+					counter += 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if counter != 8000 {
+		t.Errorf("\ntest case failed: Do() did not serialize access\nEXP: %d\nGOT: %d\n", 8000, counter)
+	}
+	mem := Alloc[byte](s, 8)
+	if !s.Owns(unsafe.Pointer(unsafe.SliceData(mem))) {
+		t.Errorf("\ntest case failed: Owns() not forwarded to the wrapped allocator\n")
+	}
+}
@@ -0,0 +1,419 @@
+package go_manual_memory
+
+import (
+	"fmt"
+	"math/bits"
+	"unsafe"
+)
+
+// Every block in a `TLSFAllocator` is a multiple of this size and aligned to it
+const TLSF_ALIGN = 16
+
+// The log2 of the number of second-level size classes in each first-level size class
+const TLSF_SL_BITS = 4
+
+// The number of first-level size classes
+const TLSF_FL_COUNT = 32
+
+// The size of each pool a `TLSFAllocator` requests from its parent if none is specified
+const DEFAULT_TLSF_POOL_SIZE = PAGE_SIZE * 128
+
+// The largest pool a `TLSFAllocator` can manage with `TLSF_FL_COUNT` first-level size classes
+const MAX_TLSF_POOL_SIZE = 1 << (TLSF_FL_COUNT + tlsfFLShift - 2)
+
+const (
+	tlsfSLCount        = 1 << TLSF_SL_BITS
+	tlsfFLShift        = TLSF_SL_BITS + 4 // log2(TLSF_ALIGN)
+	tlsfSmallBlockSize = 1 << tlsfFLShift
+	// Rounded up so the data of every block is aligned to `TLSF_ALIGN`. On 32-bit
+	// platforms the free list links then sit in the padding instead of the data
+	tlsfHeaderSize   = (unsafe.Sizeof(tlsfBlock{}) - unsafe.Sizeof(tlsfFreeLinks{}) + TLSF_ALIGN - 1) &^ (TLSF_ALIGN - 1)
+	tlsfMinBlockSize = unsafe.Sizeof(tlsfFreeLinks{})
+	tlsfFlagFree     = 1
+	tlsfFlagPrevFree = 2
+)
+
+// The free list links, stored in the data of a free block
+type tlsfFreeLinks struct {
+	next *tlsfBlock
+	prev *tlsfBlock
+}
+
+// The header in front of the data of every block in a pool
+type tlsfBlock struct {
+	// Only valid when the previous physical block is free
+	prevPhys *tlsfBlock
+	// The length of the data after the header, with the flags in the low bits
+	sizeAndFlags uintptr
+	tlsfFreeLinks
+}
+
+func (b *tlsfBlock) size() uintptr {
+	return b.sizeAndFlags &^ (tlsfFlagFree | tlsfFlagPrevFree)
+}
+
+func (b *tlsfBlock) setSize(size uintptr) {
+	b.sizeAndFlags = size | (b.sizeAndFlags & (tlsfFlagFree | tlsfFlagPrevFree))
+}
+
+func (b *tlsfBlock) isFree() bool {
+	return b.sizeAndFlags&tlsfFlagFree != 0
+}
+
+func (b *tlsfBlock) isPrevFree() bool {
+	return b.sizeAndFlags&tlsfFlagPrevFree != 0
+}
+
+func (b *tlsfBlock) setFree(free bool) {
+	if free {
+		b.sizeAndFlags |= tlsfFlagFree
+	} else {
+		b.sizeAndFlags &^= tlsfFlagFree
+	}
+}
+
+func (b *tlsfBlock) setPrevFree(free bool) {
+	if free {
+		b.sizeAndFlags |= tlsfFlagPrevFree
+	} else {
+		b.sizeAndFlags &^= tlsfFlagPrevFree
+	}
+}
+
+func (b *tlsfBlock) data() unsafe.Pointer {
+	return unsafe.Add(unsafe.Pointer(b), tlsfHeaderSize)
+}
+
+func (b *tlsfBlock) nextPhys() *tlsfBlock {
+	return (*tlsfBlock)(unsafe.Add(b.data(), b.size()))
+}
+
+func tlsfBlockOf(ptr unsafe.Pointer) *tlsfBlock {
+	return (*tlsfBlock)(unsafe.Add(ptr, -int(tlsfHeaderSize)))
+}
+
+// Return the first and second level indexes of the size class holding blocks of `size` bytes
+func tlsfMappingInsert(size uintptr) (fl, sl int) {
+	if size < tlsfSmallBlockSize {
+		return 0, int(size / (tlsfSmallBlockSize / tlsfSLCount))
+	}
+	fl = bits.Len(uint(size)) - 1
+	sl = int(size>>(fl-TLSF_SL_BITS)) ^ tlsfSLCount
+	return fl - (tlsfFLShift - 1), sl
+}
+
+// Like `tlsfMappingInsert()`, but rounds `size` up to the next size class, so
+// that every block in the returned class is large enough
+func tlsfMappingSearch(size uintptr) (fl, sl int) {
+	if size >= tlsfSmallBlockSize {
+		size += (1 << (bits.Len(uint(size)) - 1 - TLSF_SL_BITS)) - 1
+	}
+	return tlsfMappingInsert(size)
+}
+
+// This allocator implements the Two-Level Segregated Fit algorithm, which allocates
+// and frees in O(1) time with a small, fixed upper bound, making it suitable for
+// real-time threads that cannot tolerate unpredictable latency
+//
+// Free blocks are kept in a two-level table of size classes (a power-of-2 first level,
+// each split linearly into `1 << TLSF_SL_BITS` second-level classes), with a bitmap at each
+// level, so a suitable free block is always found with two bit scans. Freed blocks
+// are immediately merged with their free physical neighbors
+//
+// Memory is requested from the parent allocator in pools. Requesting a new pool is the
+// only operation that is not bounded, so real-time code should call `Reserve()` up front.
+// Requests larger than a quarter of a pool are forwarded to the parent allocator,
+// and freed based on the length passed to `RawFree()`
+//
+// The `TLSFAllocator` is NOT safe for concurrent use
+type TLSFAllocator struct {
+	parentAlloc Allocator
+	poolSize    uintptr
+	maxLen      uintptr
+	pools       [][]byte
+	flBitmap    uint32
+	slBitmaps   [TLSF_FL_COUNT]uint32
+	freeLists   [TLSF_FL_COUNT][tlsfSLCount]*tlsfBlock
+}
+
+// Create a new `TLSFAllocator` that requests pools of `poolSize` bytes from the parent
+// allocator, rounded up to a multiple of `PAGE_SIZE`. If `poolSize` is 0,
+// `DEFAULT_TLSF_POOL_SIZE` is used
+func NewTLSFAllocator(parent Allocator, poolSize uintptr) *TLSFAllocator {
+	if poolSize == 0 {
+		poolSize = DEFAULT_TLSF_POOL_SIZE
+	}
+	if uint64(poolSize) > MAX_TLSF_POOL_SIZE {
+		panic(fmt.Sprintf("fatal: go_manual_memory: NewTLSFAllocator(): pool size %d is greater than MAX_TLSF_POOL_SIZE", poolSize))
+	}
+	poolSize = alignForward(poolSize, PAGE_SIZE)
+	return &TLSFAllocator{
+		parentAlloc: parent,
+		poolSize:    poolSize,
+		maxLen:      alignForward(poolSize/4, TLSF_ALIGN),
+		pools:       make([][]byte, 0),
+	}
+}
+
+func (t *TLSFAllocator) insertFree(b *tlsfBlock) {
+	fl, sl := tlsfMappingInsert(b.size())
+	storePtr(&b.prev, nil)
+	storePtr(&b.next, t.freeLists[fl][sl])
+	if b.next != nil {
+		storePtr(&b.next.prev, b)
+	}
+	t.freeLists[fl][sl] = b
+	t.flBitmap |= 1 << fl
+	t.slBitmaps[fl] |= 1 << sl
+}
+
+func (t *TLSFAllocator) removeFree(b *tlsfBlock) {
+	fl, sl := tlsfMappingInsert(b.size())
+	if b.prev == nil {
+		t.freeLists[fl][sl] = b.next
+	} else {
+		storePtr(&b.prev.next, b.next)
+	}
+	if b.next != nil {
+		storePtr(&b.next.prev, b.prev)
+	}
+	if t.freeLists[fl][sl] == nil {
+		t.slBitmaps[fl] &^= 1 << sl
+		if t.slBitmaps[fl] == 0 {
+			t.flBitmap &^= 1 << fl
+		}
+	}
+}
+
+// Find and remove a free block of at least `size` bytes
+func (t *TLSFAllocator) findFree(size uintptr) *tlsfBlock {
+	fl, sl := tlsfMappingSearch(size)
+	if fl >= TLSF_FL_COUNT {
+		return nil
+	}
+	slMap := t.slBitmaps[fl] & (^uint32(0) << sl)
+	if slMap == 0 {
+		flMap := t.flBitmap & (^uint32(0) << (fl + 1))
+		if flMap == 0 {
+			return nil
+		}
+		fl = bits.TrailingZeros32(flMap)
+		slMap = t.slBitmaps[fl]
+	}
+	sl = bits.TrailingZeros32(slMap)
+	b := t.freeLists[fl][sl]
+	t.removeFree(b)
+	return b
+}
+
+// Mark a block as free and insert it into the free lists, merging it with its
+// free physical neighbors first
+func (t *TLSFAllocator) releaseBlock(b *tlsfBlock) {
+	if b.isPrevFree() {
+		prev := b.prevPhys
+		t.removeFree(prev)
+		prev.setSize(prev.size() + tlsfHeaderSize + b.size())
+		b = prev
+	}
+	next := b.nextPhys()
+	if next.isFree() {
+		t.removeFree(next)
+		b.setSize(b.size() + tlsfHeaderSize + next.size())
+		next = b.nextPhys()
+	}
+	b.setFree(true)
+	storePtr(&next.prevPhys, b)
+	next.setPrevFree(true)
+	t.insertFree(b)
+}
+
+// Split the end of a used block off into a new free block, if it is large
+// enough to hold more than `size` bytes and another block
+func (t *TLSFAllocator) trimUsed(b *tlsfBlock, size uintptr) {
+	if b.size() < size+tlsfHeaderSize+tlsfMinBlockSize {
+		return
+	}
+	rest := (*tlsfBlock)(unsafe.Add(b.data(), size))
+	rest.sizeAndFlags = b.size() - size - tlsfHeaderSize
+	b.setSize(size)
+	storePtr(&rest.nextPhys().prevPhys, rest)
+	t.releaseBlock(rest)
+}
+
+// Split the start of a free block off into a new free block, so that the rest
+// begins with data at `aligned`, returning the rest
+func (t *TLSFAllocator) trimFreeLeading(b *tlsfBlock, aligned unsafe.Pointer) *tlsfBlock {
+	rest := tlsfBlockOf(aligned)
+	gap := uintptr(unsafe.Pointer(rest)) - uintptr(unsafe.Pointer(b))
+	rest.sizeAndFlags = b.size() - gap
+	b.setSize(gap - tlsfHeaderSize)
+	storePtr(&rest.prevPhys, b)
+	rest.setPrevFree(true)
+	storePtr(&rest.nextPhys().prevPhys, rest)
+	t.insertFree(b)
+	return rest
+}
+
+// Request a new pool from the parent allocator and add it as a single free block,
+// followed by a zero-length used sentinel block
+func (t *TLSFAllocator) addNewPool() error {
+	ptr, _, err := TryRawAlloc(t.parentAlloc, t.poolSize, TLSF_ALIGN)
+	if err != nil {
+		return err
+	}
+	pool := unsafe.Slice((*byte)(ptr), t.poolSize)
+	t.pools = append(t.pools, pool)
+	b := (*tlsfBlock)(ptr)
+	b.sizeAndFlags = t.poolSize - tlsfHeaderSize*2
+	sentinel := b.nextPhys()
+	sentinel.sizeAndFlags = 0
+	storePtr(&sentinel.prevPhys, b)
+	b.setFree(true)
+	sentinel.setPrevFree(true)
+	t.insertFree(b)
+	return nil
+}
+
+// Request enough pools from the parent allocator to hold at least `len` more bytes,
+// so that later allocations up to that total never need to request a new pool
+//
+// This is only a guarantee when the space is not fragmented by allocations of
+// different sizes being interleaved with frees
+func (t *TLSFAllocator) Reserve(len uintptr) error {
+	usable := t.poolSize - tlsfHeaderSize*2
+	for range (len + usable - 1) / usable {
+		if err := t.addNewPool(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RawAlloc implements Allocator.
+func (t *TLSFAllocator) RawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr) {
+	ptr, alloc_len, err := t.TryRawAlloc(len, align)
+	if err != nil {
+		panic("fatal: " + err.Error())
+	}
+	return
+}
+
+// TryRawAlloc implements FallibleAllocator.
+func (t *TLSFAllocator) TryRawAlloc(len uintptr, align uintptr) (ptr unsafe.Pointer, alloc_len uintptr, err error) {
+	if len == 0 {
+		return nil, 0, nil
+	}
+	if len > t.maxLen {
+		return TryRawAlloc(t.parentAlloc, len, align)
+	}
+	size := alignForward(max(len, tlsfMinBlockSize), TLSF_ALIGN)
+	// Larger alignments are satisfied by finding a block with room to
+	// split off a free block in front of the aligned data
+	gapMin := uintptr(0)
+	if align > TLSF_ALIGN {
+		gapMin = tlsfHeaderSize + tlsfMinBlockSize
+		if align > t.poolSize/4 {
+			return nil, 0, fmt.Errorf("%w: TLSFAllocator.TryRawAlloc(): requested align %d is greater than a quarter of the pool size %d", ErrOutOfMemory, align, t.poolSize)
+		}
+	}
+	search := size
+	if gapMin > 0 {
+		search += align + gapMin
+	}
+	b := t.findFree(search)
+	if b == nil {
+		if err = t.addNewPool(); err != nil {
+			return nil, 0, err
+		}
+		if b = t.findFree(search); b == nil {
+			return nil, 0, fmt.Errorf("%w: TLSFAllocator.TryRawAlloc(): new pool cannot hold requested len %d (align %d)", ErrOutOfMemory, len, align)
+		}
+	}
+	if gapMin > 0 {
+		gap := alignForward(uintptr(b.data()), align) - uintptr(b.data())
+		if gap > 0 && gap < gapMin {
+			gap = alignForward(uintptr(b.data())+gapMin, align) - uintptr(b.data())
+		}
+		if gap > 0 {
+			b = t.trimFreeLeading(b, unsafe.Add(b.data(), gap))
+		}
+	}
+	b.setFree(false)
+	b.nextPhys().setPrevFree(false)
+	t.trimUsed(b, size)
+	return b.data(), size, nil
+}
+
+// RawFree implements Allocator.
+//
+// The length of the block is read from its header, so `len` is only
+// used to tell whether it was forwarded to the parent allocator
+func (t *TLSFAllocator) RawFree(ptr unsafe.Pointer, len uintptr) {
+	if ptr == nil || len == 0 {
+		return
+	}
+	if len > t.maxLen {
+		t.parentAlloc.RawFree(ptr, len)
+		return
+	}
+	b := tlsfBlockOf(ptr)
+	if b.isFree() {
+		panic(fmt.Sprintf("fatal: go_manual_memory: TLSFAllocator.RawFree(): double free of pointer 0x%x", uintptr(ptr)))
+	}
+	t.releaseBlock(b)
+}
+
+// RawResizeInPlace implements Allocator.
+//
+// Growing succeeds when the next physical block is free and large enough
+func (t *TLSFAllocator) RawResizeInPlace(ptr unsafe.Pointer, old_len uintptr, new_len uintptr) (newPtr unsafe.Pointer, success bool) {
+	if old_len > t.maxLen {
+		if new_len <= t.maxLen {
+			return ptr, false
+		}
+		return t.parentAlloc.RawResizeInPlace(ptr, old_len, new_len)
+	}
+	if new_len > t.maxLen {
+		return ptr, false
+	}
+	b := tlsfBlockOf(ptr)
+	size := alignForward(max(new_len, tlsfMinBlockSize), TLSF_ALIGN)
+	if size > b.size() {
+		next := b.nextPhys()
+		if !next.isFree() || b.size()+tlsfHeaderSize+next.size() < size {
+			return ptr, false
+		}
+		t.removeFree(next)
+		b.setSize(b.size() + tlsfHeaderSize + next.size())
+		b.nextPhys().setPrevFree(false)
+	}
+	t.trimUsed(b, size)
+	return ptr, true
+}
+
+// Owns implements Owner.
+func (t *TLSFAllocator) Owns(ptr unsafe.Pointer) bool {
+	for _, pool := range t.pools {
+		if blockContains(pool, ptr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return all pools to the parent allocator
+//
+// All memory allocated from this allocator becomes invalid. Any allocations
+// forwarded to the parent allocator are NOT freed
+func (t *TLSFAllocator) Destroy() {
+	for _, pool := range t.pools {
+		t.parentAlloc.RawFree(unsafe.Pointer(unsafe.SliceData(pool)), t.poolSize)
+	}
+	t.pools = t.pools[:0]
+	t.flBitmap = 0
+	t.slBitmaps = [TLSF_FL_COUNT]uint32{}
+	t.freeLists = [TLSF_FL_COUNT][tlsfSLCount]*tlsfBlock{}
+}
+
+var _ FallibleAllocator = (*TLSFAllocator)(nil)
+var _ Owner = (*TLSFAllocator)(nil)
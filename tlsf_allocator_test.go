@@ -0,0 +1,160 @@
+package go_manual_memory
+
+import (
+	"errors"
+	"math/bits"
+	"testing"
+	"unsafe"
+)
+
+// Walk the physical blocks of every pool of `t`, checking the flags and links agree,
+// and return the number of free blocks found
+func checkTLSFPools(t *testing.T, tlsf *TLSFAllocator) (freeBlocks int) {
+	t.Helper()
+	for _, pool := range tlsf.pools {
+		b := (*tlsfBlock)(unsafe.Pointer(unsafe.SliceData(pool)))
+		prevFree := false
+		var prev *tlsfBlock
+		for {
+			if b.isPrevFree() != prevFree {
+				t.Fatalf("\ntest case failed: prev free flag\nBLOCK: %p\nEXP: %v\nGOT: %v\n", b, prevFree, b.isPrevFree())
+			}
+			if prevFree && b.prevPhys != prev {
+				t.Fatalf("\ntest case failed: prev physical link\nBLOCK: %p\nEXP: %p\nGOT: %p\n", b, prev, b.prevPhys)
+			}
+			if b.size() == 0 {
+				break
+			}
+			if b.isFree() {
+				if prevFree {
+					t.Fatalf("\ntest case failed: free neighbors not merged\nBLOCK: %p\n", b)
+				}
+				freeBlocks += 1
+			}
+			prevFree = b.isFree()
+			prev = b
+			b = b.nextPhys()
+		}
+		if end := uintptr(unsafe.Pointer(b)) + tlsfHeaderSize; end != uintptr(unsafe.Pointer(unsafe.SliceData(pool)))+uintptr(len(pool)) {
+			t.Fatalf("\ntest case failed: sentinel not at the end of the pool\n")
+		}
+	}
+	return freeBlocks
+}
+
+func TestTLSFAllocator(t *testing.T) {
+	parent := NewGoAllocator()
+	tlsf := NewTLSFAllocator(parent, PAGE_SIZE*4)
+	live := stressAllocator(t, tlsf, 1, 10000, PAGE_SIZE*2, PAGE_SIZE)
+	checkTLSFPools(t, tlsf)
+	freeTestBlocks(tlsf, live)
+	if freeBlocks := checkTLSFPools(t, tlsf); freeBlocks != len(tlsf.pools) {
+		t.Errorf("\ntest case failed: pools not fully merged after freeing everything\nEXP: %d\nGOT: %d\n", len(tlsf.pools), freeBlocks)
+	}
+	tlsf.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
+
+func TestTLSFAllocatorMapping(t *testing.T) {
+	tests := []struct {
+		size               uintptr
+		insertFL, insertSL int
+		searchFL, searchSL int
+	}{
+		{16, 0, 1, 0, 1},
+		{255, 0, 15, 0, 15},
+		{256, 1, 0, 1, 0},
+		{257, 1, 0, 1, 1},
+		{272, 1, 1, 1, 1},
+		{511, 1, 15, 2, 0},
+		{1 << 20, 13, 0, 13, 0},
+	}
+	for _, test := range tests {
+		if fl, sl := tlsfMappingInsert(test.size); fl != test.insertFL || sl != test.insertSL {
+			t.Errorf("\ntest case failed: insert mapping\nSIZE: %d\nEXP: %d, %d\nGOT: %d, %d\n", test.size, test.insertFL, test.insertSL, fl, sl)
+		}
+		if fl, sl := tlsfMappingSearch(test.size); fl != test.searchFL || sl != test.searchSL {
+			t.Errorf("\ntest case failed: search mapping\nSIZE: %d\nEXP: %d, %d\nGOT: %d, %d\n", test.size, test.searchFL, test.searchSL, fl, sl)
+		}
+	}
+}
+
+func TestTLSFAllocatorAligned(t *testing.T) {
+	tlsf := NewTLSFAllocator(NewGoAllocator(), PAGE_SIZE*4)
+	ptrs := make([]unsafe.Pointer, 0)
+	for align := uintptr(1); align <= PAGE_SIZE; align <<= 1 {
+		ptr, alloc_len := tlsf.RawAlloc(24, align)
+		if uintptr(ptr)&(align-1) != 0 || alloc_len != 32 {
+			t.Errorf("\ntest case failed: aligned block\nALIGN: %d\nADDR: 0x%x\nLEN: %d\n", align, uintptr(ptr), alloc_len)
+		}
+		ptrs = append(ptrs, ptr)
+	}
+	checkTLSFPools(t, tlsf)
+	for _, ptr := range ptrs {
+		tlsf.RawFree(ptr, 24)
+	}
+	if freeBlocks := checkTLSFPools(t, tlsf); freeBlocks != len(tlsf.pools) {
+		t.Errorf("\ntest case failed: pools not fully merged after freeing everything\nEXP: %d\nGOT: %d\n", len(tlsf.pools), freeBlocks)
+	}
+	if _, _, err := tlsf.TryRawAlloc(24, PAGE_SIZE*2); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: align greater than a quarter of the pool\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+}
+
+func TestTLSFAllocatorResizeInPlace(t *testing.T) {
+	tlsf := NewTLSFAllocator(NewGoAllocator(), PAGE_SIZE*4)
+	a, _ := tlsf.RawAlloc(64, 16)
+	b, _ := tlsf.RawAlloc(64, 16)
+	if _, ok := tlsf.RawResizeInPlace(a, 64, 65); ok {
+		t.Errorf("\ntest case failed: grew into a live neighbor\n")
+	}
+	if _, ok := tlsf.RawResizeInPlace(b, 64, 1000); !ok {
+		t.Errorf("\ntest case failed: grow into a free neighbor\n")
+	}
+	if _, ok := tlsf.RawResizeInPlace(b, 1000, 16); !ok {
+		t.Errorf("\ntest case failed: shrink\n")
+	}
+	checkTLSFPools(t, tlsf)
+	tlsf.RawFree(b, 16)
+	if _, ok := tlsf.RawResizeInPlace(a, 64, 500); !ok {
+		t.Errorf("\ntest case failed: grow into a freed neighbor\n")
+	}
+	if _, ok := tlsf.RawResizeInPlace(a, 500, PAGE_SIZE*2); ok {
+		t.Errorf("\ntest case failed: grew past the max length\n")
+	}
+	tlsf.RawFree(a, 500)
+	if freeBlocks := checkTLSFPools(t, tlsf); freeBlocks != 1 {
+		t.Errorf("\ntest case failed: pool not fully merged after freeing everything\nGOT: %d\n", freeBlocks)
+	}
+	expectPanic(t, "double free", func() { tlsf.RawFree(a, 500) })
+}
+
+func TestTLSFAllocatorPools(t *testing.T) {
+	parent := NewGoAllocator()
+	tlsf := NewTLSFAllocator(parent, PAGE_SIZE)
+	if err := tlsf.Reserve(PAGE_SIZE * 2); err != nil || len(tlsf.pools) != 3 {
+		t.Errorf("\ntest case failed: reserve\nEXP POOLS: 3\nGOT POOLS: %d\nGOT ERR: %v\n", len(tlsf.pools), err)
+	}
+	large, large_len := tlsf.RawAlloc(PAGE_SIZE, 8)
+	if tlsf.Owns(large) || parent.LiveCount() != 4 {
+		t.Errorf("\ntest case failed: large request not forwarded\nOWNED: %v\nPARENT LIVE: %d\n", tlsf.Owns(large), parent.LiveCount())
+	}
+	tlsf.RawFree(large, large_len)
+	failing := NewTLSFAllocator(failingAllocator{parent, 0}, PAGE_SIZE)
+	if _, _, err := failing.TryRawAlloc(16, 16); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: parent failure\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if err := failing.Reserve(1); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("\ntest case failed: reserve with parent failure\nEXP ERR: %v\nGOT ERR: %v\n", ErrOutOfMemory, err)
+	}
+	if bits.UintSize == 64 {
+		tooLarge := uint64(MAX_TLSF_POOL_SIZE) + 1
+		expectPanic(t, "pool size over MAX_TLSF_POOL_SIZE", func() { NewTLSFAllocator(parent, uintptr(tooLarge)) })
+	}
+	tlsf.Destroy()
+	if parent.LiveCount() != 0 {
+		t.Errorf("\ntest case failed: memory left in parent after Destroy()\nGOT: %d\n", parent.LiveCount())
+	}
+}
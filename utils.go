@@ -1,6 +1,9 @@
 package go_manual_memory
 
-import "unsafe"
+import (
+	"math"
+	"unsafe"
+)
 
 func UnsafeCastPtr[IN any, OUT any](in *IN) *OUT {
 	return (*OUT)(unsafe.Pointer(in))
@@ -9,3 +12,39 @@ func UnsafeCastPtr[IN any, OUT any](in *IN) *OUT {
 func UnsafeCast[IN any, OUT any](in IN) OUT {
 	return *(*OUT)(unsafe.Pointer(&in))
 }
+
+// Return whether `ptr` points into the memory of `block`
+func blockContains(block []byte, ptr unsafe.Pointer) bool {
+	start := uintptr(unsafe.Pointer(unsafe.SliceData(block)))
+	return uintptr(ptr) >= start && uintptr(ptr) < start+uintptr(cap(block))
+}
+
+// Convert a capacity to the `uint32` stored by `Slice[T]`, `List[T]`, `Map[K, V]`, and
+// `Deque[T]`, clamping it to the largest capacity they can hold. Every write of a
+// computed capacity goes through here
+//
+// An allocator may return more memory than a `uint32` capacity can describe. The extra
+// is simply unused, and is still freed correctly
+func clampCap(cap int) uint32 {
+	return uint32(min(uint64(cap), math.MaxUint32))
+}
+
+// Round `val` up to the next multiple of `align`, which MUST be a power of 2
+func alignForward(val, align uintptr) uintptr {
+	return (val + align - 1) & ^(align - 1)
+}
+
+// Store `val` at `dst` in manually managed memory WITHOUT a garbage collector write barrier
+//
+// Freed memory still holds whatever bytes its last user left behind, and a normal pointer
+// store lets the write barrier read those bytes as a pointer, which crashes the garbage
+// collector if they happen to point at a freed Golang object. Whatever `val` points to
+// MUST be kept alive by some other reference, like the allocator's list of blocks
+func storeUnsafePtr(dst *unsafe.Pointer, val unsafe.Pointer) {
+	*(*uintptr)(unsafe.Pointer(dst)) = uintptr(val)
+}
+
+// Like `storeUnsafePtr()`, for typed pointers such as free list links
+func storePtr[T any](dst **T, val *T) {
+	storeUnsafePtr((*unsafe.Pointer)(unsafe.Pointer(dst)), unsafe.Pointer(val))
+}